@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/imgk/shadow/experimental/clashapi"
+	"github.com/imgk/shadow/pkg/outbound"
+	"github.com/imgk/shadow/pkg/rules"
+)
+
+// fakeClashState is a minimal clashapi.StateProvider for constructing a
+// Controller without a full App.
+type fakeClashState struct {
+	matcher   *rules.Matcher
+	outbounds *outbound.Registry
+}
+
+func (s *fakeClashState) Matcher() *rules.Matcher       { return s.matcher }
+func (s *fakeClashState) Outbounds() *outbound.Registry { return s.outbounds }
+func (s *fakeClashState) Reload(path string) error      { return nil }
+
+// TestDialWrapsConnWithTrackerWhenControllerEnabled verifies the wiring
+// chunk0-1 was missing: with the Clash API enabled, App.Dial must route
+// the dialed conn through the controller's Tracker so /connections and
+// /traffic report real traffic instead of staying empty forever.
+func TestDialWrapsConnWithTrackerWhenControllerEnabled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	matcher, err := rules.New([]rules.RuleConfig{{Type: "MATCH", Outbound: "DIRECT"}}, nil)
+	if err != nil {
+		t.Fatalf("rules.New() error = %v", err)
+	}
+	defer matcher.Close()
+	registry, err := outbound.NewRegistry([]outbound.OutboundConfig{{Name: "DIRECT", Type: "direct"}}, nil)
+	if err != nil {
+		t.Fatalf("outbound.NewRegistry() error = %v", err)
+	}
+	defer registry.Close()
+
+	app := &App{}
+	app.cur.Store(&state{matcher: matcher, outbounds: registry})
+	app.controller = clashapi.NewController("127.0.0.1:0", "", &fakeClashState{matcher: matcher, outbounds: registry})
+
+	conn, err := app.Dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 4)
+	conn.Write(buf)
+
+	up, _ := app.controller.Tracker().Traffic()
+	if up != uint64(len(buf)) {
+		t.Errorf("Tracker().Traffic() up = %d, want %d (Dial's conn must be tracker-wrapped)", up, len(buf))
+	}
+
+	conns := app.controller.Tracker().Conns()
+	if len(conns) != 1 {
+		t.Fatalf("len(Conns()) = %d, want 1", len(conns))
+	}
+	if conns[0].Metadata.Rule != "DIRECT" {
+		t.Errorf("Metadata.Rule = %q, want %q", conns[0].Metadata.Rule, "DIRECT")
+	}
+	if conns[0].Metadata.Destination != ln.Addr().String() {
+		t.Errorf("Metadata.Destination = %q, want %q", conns[0].Metadata.Destination, ln.Addr().String())
+	}
+}
@@ -1,25 +1,39 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/imgk/shadow/experimental/clashapi"
 	"github.com/imgk/shadow/pkg/logger"
-	"github.com/imgk/shadow/pkg/suffixtree"
+	"github.com/imgk/shadow/pkg/metrics"
+	"github.com/imgk/shadow/pkg/outbound"
+	"github.com/imgk/shadow/pkg/resolver"
+	"github.com/imgk/shadow/pkg/rules"
 )
 
 // Conf is shadow application configuration
 type Conf struct {
 	// Server Config
-	Server      string `json:"server"`
+	Server string `json:"server"`
+	// NameServer is one or more (comma-separated) upstream resolver
+	// URIs: an sdns:// stamp, or a plain udp://, tls://, https:// or
+	// quic:// URI. See pkg/resolver for the supported transports.
 	NameServer  string `json:"name_server"`
 	ProxyServer string `json:"proxy_server,omitempty"`
 
@@ -48,6 +62,83 @@ type Conf struct {
 		Direct  []string `json:"direct,omitempty"`
 		Blocked []string `json:"blocked,omitempty"`
 	} `json:"domain_rules"`
+
+	// Rules is the ordered rule list matched in order, first match wins.
+	// If empty, it is populated from GeoIP, IPCIDRRules and DomainRules
+	// for backward compatibility with older config files.
+	Rules []rules.RuleConfig `json:"rules,omitempty"`
+
+	// Outbounds are the concrete proxy backends a rule's Outbound name
+	// can refer to. OutboundGroups are evaluated afterward and may
+	// additionally refer to any earlier group.
+	Outbounds      []outbound.OutboundConfig `json:"outbounds,omitempty"`
+	OutboundGroups []outbound.GroupConfig    `json:"outbound_groups,omitempty"`
+
+	// ReloadGracePeriod is how long App.Reload keeps a superseded
+	// resolver, rule matcher and outbound registry open after being
+	// replaced, as a Go duration string (e.g. "30s"). Defaults to 30s.
+	ReloadGracePeriod string `json:"reload_grace_period,omitempty"`
+
+	// Admin groups runtime-management subsystems that listen on their
+	// own HTTP servers, distinct from the Clash API enabled via
+	// App.EnableClashAPI.
+	Admin struct {
+		// Metrics, if enabled, serves GET /metrics in Prometheus text
+		// exposition format on Listen (default "127.0.0.1:9090").
+		Metrics struct {
+			Enabled bool   `json:"enabled"`
+			Listen  string `json:"listen,omitempty"`
+		} `json:"metrics,omitempty"`
+	} `json:"admin,omitempty"`
+}
+
+// legacyRules translates the older GeoIP/IPCIDRRules/DomainRules fields
+// into an equivalent ordered rules.RuleConfig list, so config files
+// written before the rules engine existed keep working unmodified.
+func (c *Conf) legacyRules() []rules.RuleConfig {
+	cfgs := make([]rules.RuleConfig, 0)
+
+	for _, domain := range c.DomainRules.Blocked {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "DOMAIN-SUFFIX", Value: domain, Outbound: "BLOCKED"})
+	}
+	for _, domain := range c.DomainRules.Direct {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "DOMAIN-SUFFIX", Value: domain, Outbound: "DIRECT"})
+	}
+	for _, domain := range c.DomainRules.Proxy {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "DOMAIN-SUFFIX", Value: domain, Outbound: "PROXY"})
+	}
+
+	for _, cidr := range c.IPCIDRRules.Proxy {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "IP-CIDR", Value: cidr, Outbound: "PROXY"})
+	}
+
+	for _, code := range c.GeoIP.Proxy {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "GEOIP", Value: code, Outbound: "PROXY"})
+	}
+	for _, code := range c.GeoIP.Bypass {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "GEOIP", Value: code, Outbound: "DIRECT"})
+	}
+	if c.GeoIP.Final != "" {
+		cfgs = append(cfgs, rules.RuleConfig{Type: "MATCH", Outbound: strings.ToUpper(c.GeoIP.Final)})
+	}
+
+	return cfgs
+}
+
+// legacyOutbounds synthesizes the builtin outbounds legacyRules refers
+// to by name: PROXY (a socks5 dial to the old top-level Server field,
+// if set), DIRECT, and BLOCKED. Without these, a legacy config's "PROXY"
+// rules would name an outbound that was never built and silently fall
+// through to a direct dial.
+func (c *Conf) legacyOutbounds() []outbound.OutboundConfig {
+	cfgs := []outbound.OutboundConfig{
+		{Name: "DIRECT", Type: "direct"},
+		{Name: "BLOCKED", Type: "block"},
+	}
+	if c.Server != "" {
+		cfgs = append(cfgs, outbound.OutboundConfig{Name: "PROXY", Type: "socks5", Server: c.Server})
+	}
+	return cfgs
 }
 
 // ReadFromFile is to read config from file
@@ -85,15 +176,97 @@ func (c *Conf) ReadFromByteSlice(b []byte) error {
 		c.GeoIP.Bypass = append(c.GeoIP.Bypass, strings.ToUpper(v))
 	}
 	c.GeoIP.Final = strings.ToLower(c.GeoIP.Final)
+	if len(c.Rules) == 0 {
+		c.Rules = c.legacyRules()
+		if len(c.Outbounds) == 0 && len(c.OutboundGroups) == 0 {
+			c.Outbounds = c.legacyOutbounds()
+		}
+	}
 	return nil
 }
 
+// defaultGracePeriod is the ReloadGracePeriod used when Conf doesn't
+// set one.
+const defaultGracePeriod = 30 * time.Second
+
+// defaultMetricsListen is the Admin.Metrics.Listen used when enabled
+// without an explicit address.
+const defaultMetricsListen = "127.0.0.1:9090"
+
+// state is the bundle of config-derived subsystems App.Reload replaces
+// atomically: everything a Dial in flight needs to keep running
+// unaffected by a reload started after it began, plus the Conf and
+// gracePeriod it was built from, so App.Conf and a reloaded
+// reload_grace_period never go stale after a Reload.
+type state struct {
+	conf        *Conf
+	gracePeriod time.Duration
+	matcher     *rules.Matcher
+	outbounds   *outbound.Registry
+	resolver    resolver.Resolver
+}
+
+// closers returns every io.Closer this state generation owns, to be
+// closed once no new Dial can observe it.
+func (st *state) closers() []io.Closer {
+	closers := make([]io.Closer, 0, 3)
+	if st.resolver != nil {
+		closers = append(closers, st.resolver)
+	}
+	if st.matcher != nil {
+		closers = append(closers, st.matcher)
+	}
+	if st.outbounds != nil {
+		closers = append(closers, st.outbounds)
+	}
+	return closers
+}
+
+// buildState parses conf's reload grace period, resolver, rule matcher
+// and outbound registry. On error, anything it already opened is closed
+// before returning.
+func buildState(conf *Conf) (*state, error) {
+	gracePeriod := defaultGracePeriod
+	if conf.ReloadGracePeriod != "" {
+		d, err := time.ParseDuration(conf.ReloadGracePeriod)
+		if err != nil {
+			return nil, err
+		}
+		gracePeriod = d
+	}
+
+	re, err := resolver.NewGroup(strings.Split(conf.NameServer, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := NewRuleMatcher(conf)
+	if err != nil {
+		re.Close()
+		return nil, err
+	}
+
+	registry, err := NewOutboundRegistry(conf)
+	if err != nil {
+		re.Close()
+		matcher.Close()
+		return nil, err
+	}
+
+	return &state{conf: conf, gracePeriod: gracePeriod, matcher: matcher, outbounds: registry, resolver: re}, nil
+}
+
 // App is shadow application
 type App struct {
 	Logger logger.Logger
-	Conf   *Conf
 
 	timeout time.Duration
+	w       io.Writer
+
+	cur      atomic.Value // *state
+	reloadMu sync.Mutex
+
+	controller *clashapi.Controller
 
 	closed  chan struct{}
 	closers []io.Closer
@@ -106,7 +279,7 @@ func NewApp(file string, timeout time.Duration, w io.Writer) (*App, error) {
 		return nil, err
 	}
 
-	return NewAppFromConf(conf, timeout, w), nil
+	return NewAppFromConf(conf, timeout, w)
 }
 
 // NewAppFromByteSlice is new shadow app from byte slice
@@ -116,25 +289,203 @@ func NewAppFromByteSlice(b []byte, timeout time.Duration, w io.Writer) (*App, er
 		return nil, err
 	}
 
-	return NewAppFromConf(conf, timeout, w), nil
+	return NewAppFromConf(conf, timeout, w)
 }
 
 // NewAppFromConf is new shadow app from *Conf
-func NewAppFromConf(conf *Conf, timeout time.Duration, w io.Writer) *App {
+func NewAppFromConf(conf *Conf, timeout time.Duration, w io.Writer) (*App, error) {
+	st, err := buildState(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	app := &App{
 		Logger:  logger.NewLogger(w),
-		Conf:    conf,
 		timeout: timeout,
+		w:       w,
 		closed:  make(chan struct{}),
 		closers: []io.Closer{},
 	}
-	return app
+	app.cur.Store(st)
+
+	if conf.Admin.Metrics.Enabled {
+		addr := conf.Admin.Metrics.Listen
+		if addr == "" {
+			addr = defaultMetricsListen
+		}
+		srv, err := metrics.Serve(addr)
+		if err != nil {
+			app.Close()
+			return nil, err
+		}
+		app.attachCloser(srv)
+	}
+
+	return app, nil
+}
+
+// state returns the currently active subsystem bundle.
+func (app *App) state() *state {
+	return app.cur.Load().(*state)
+}
+
+// Conf returns the configuration App was most recently built or
+// reloaded from.
+func (app *App) Conf() *Conf {
+	return app.state().conf
+}
+
+// Resolver returns the DNS resolver built from Conf.NameServer.
+func (app *App) Resolver() resolver.Resolver {
+	return app.state().resolver
+}
+
+// Matcher returns the rule matcher built from Conf.Rules.
+func (app *App) Matcher() *rules.Matcher {
+	return app.state().matcher
+}
+
+// Outbounds returns the outbound registry built from Conf.Outbounds and
+// Conf.OutboundGroups.
+func (app *App) Outbounds() *outbound.Registry {
+	return app.state().outbounds
+}
+
+// Reload re-parses the config file at path and atomically swaps in a
+// new Conf, resolver, rule matcher and outbound registry. Connections
+// dialed before Reload returns keep using the state they were dialed
+// with; the superseded state is closed only after the new config's
+// reload grace period (or App.Close, whichever comes first), so they
+// have time to finish. If the new config fails to load, the previous
+// state is left fully in place.
+func (app *App) Reload(path string) error {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	conf := new(Conf)
+	if err := conf.ReadFromFile(path); err != nil {
+		return err
+	}
+
+	next, err := buildState(conf)
+	if err != nil {
+		return err
+	}
+
+	prev := app.state()
+	app.cur.Store(next)
+
+	go func() {
+		select {
+		case <-time.After(next.gracePeriod):
+		case <-app.closed:
+		}
+		for _, c := range prev.closers() {
+			c.Close()
+		}
+	}()
+	return nil
+}
+
+// Dial consults the rule matcher to pick an outbound or group for addr,
+// then dials addr through it, reading the current state once so a
+// concurrent Reload cannot change which state this connection uses. If
+// no rule matches, it dials directly. When the Clash API is enabled, the
+// returned conn is wrapped by its Tracker so /connections and /traffic
+// report real traffic.
+//
+// Dial has no source address for addr, so SRC-IP-CIDR, SRC-PORT and
+// PROCESS-NAME rules never match through this path; only NETWORK and
+// destination-based rule types (DOMAIN*, IP-CIDR, GEOIP, DST-PORT, MATCH)
+// are usable here.
+func (app *App) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	rule := "DIRECT"
+	o := dialOutbound(app.state(), network, addr)
+
+	var conn net.Conn
+	var err error
+	if o == nil {
+		conn, err = (&net.Dialer{}).DialContext(ctx, network, addr)
+	} else {
+		rule = o.Name()
+		conn, err = o.Dial(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if app.controller == nil {
+		return conn, nil
+	}
+	meta := clashapi.Metadata{
+		Network:     network,
+		Source:      conn.LocalAddr().String(),
+		Destination: addr,
+		Rule:        rule,
+	}
+	return app.controller.Tracker().WrapConn(conn, meta), nil
+}
+
+// dialOutbound has no socket-level source address to offer, since it
+// runs before any connection exists: SRC-IP-CIDR, SRC-PORT and
+// PROCESS-NAME rules are matched against a zero-valued Metadata.SrcIP/
+// SrcPort here and so never fire through App.Dial. A caller that wants
+// those rule types to work needs to resolve the outbound itself from a
+// Metadata it populates directly, e.g. from a tun/WinDivert packet.
+func dialOutbound(st *state, network, addr string) outbound.Outbound {
+	if st.matcher == nil || st.outbounds == nil {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+
+	dstPort, _ := strconv.Atoi(port)
+	meta := &rules.Metadata{Network: network, DstPort: dstPort}
+	if ip := net.ParseIP(host); ip != nil {
+		meta.DstIP = ip
+	} else {
+		meta.Domain = host
+	}
+
+	name, ok := st.matcher.Match(meta)
+	if !ok {
+		return nil
+	}
+
+	o, ok := st.outbounds.Get(name)
+	if !ok {
+		return nil
+	}
+	return o
 }
 
 func (app *App) attachCloser(closer io.Closer) {
 	app.closers = append(app.closers, closer)
 }
 
+// Controller returns the Clash API controller, or nil if EnableClashAPI
+// has not been called.
+func (app *App) Controller() *clashapi.Controller {
+	return app.controller
+}
+
+// EnableClashAPI starts a Clash-compatible control API listening on addr,
+// guarded by secret.
+func (app *App) EnableClashAPI(addr, secret string) error {
+	ctrl := clashapi.NewController(addr, secret, app)
+	if err := ctrl.Start(); err != nil {
+		return err
+	}
+
+	app.controller = ctrl
+	app.Logger = logger.NewLogger(io.MultiWriter(app.w, ctrl))
+	app.attachCloser(ctrl)
+	return nil
+}
+
 // Done is to give done channel
 func (app *App) Done() chan struct{} {
 	return app.closed
@@ -147,28 +498,38 @@ func (app *App) Close() error {
 		return nil
 	default:
 	}
+	close(app.closed)
+
 	for _, closer := range app.closers {
 		closer.Close()
 	}
-	close(app.closed)
+	for _, closer := range app.state().closers() {
+		closer.Close()
+	}
 	return nil
 }
 
-// NewDomainTree is ...
-func NewDomainTree(app *App) (*suffixtree.DomainTree, error) {
-	tree := suffixtree.NewDomainTree(".")
-	tree.Lock()
-	for _, domain := range app.Conf.DomainRules.Proxy {
-		tree.UnsafeStore(domain, &suffixtree.DomainEntry{Rule: "PROXY"})
-	}
-	for _, domain := range app.Conf.DomainRules.Direct {
-		tree.UnsafeStore(domain, &suffixtree.DomainEntry{Rule: "DIRECT"})
-	}
-	for _, domain := range app.Conf.DomainRules.Blocked {
-		tree.UnsafeStore(domain, &suffixtree.DomainEntry{Rule: "BLOCKED"})
+// NewRuleMatcher builds the rule matcher described by conf.Rules. It
+// opens conf.GeoIP.File whenever it is set, whether or not any GEOIP
+// rule uses it; the returned
+// Matcher owns that handle and closes it in Close.
+func NewRuleMatcher(conf *Conf) (*rules.Matcher, error) {
+	var geoDB *maxminddb.Reader
+	if conf.GeoIP.File != "" {
+		db, err := maxminddb.Open(conf.GeoIP.File)
+		if err != nil {
+			return nil, err
+		}
+		geoDB = db
 	}
-	tree.Unlock()
-	return tree, nil
+
+	return rules.New(conf.Rules, geoDB)
+}
+
+// NewOutboundRegistry builds the outbound registry described by
+// conf.Outbounds and conf.OutboundGroups.
+func NewOutboundRegistry(conf *Conf) (*outbound.Registry, error) {
+	return outbound.NewRegistry(conf.Outbounds, conf.OutboundGroups)
 }
 
 // ServePAC is to serve proxy pac file
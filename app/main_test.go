@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestLegacyConfigDialsThroughConfiguredServer verifies the compat path
+// chunk0-3 added: a config that only sets the old Server/DomainRules
+// fields must still route "proxy" traffic to Server, not fall through
+// to a direct dial.
+func TestLegacyConfigDialsThroughConfiguredServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	dialed := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 3)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _ := conn.Read(buf)
+		dialed <- buf[:n]
+	}()
+
+	conf := &Conf{Server: ln.Addr().String()}
+	conf.DomainRules.Proxy = []string{"example.com"}
+	if err := conf.ReadFromByteSlice([]byte("{}")); err != nil {
+		t.Fatalf("ReadFromByteSlice() error = %v", err)
+	}
+
+	matcher, err := NewRuleMatcher(conf)
+	if err != nil {
+		t.Fatalf("NewRuleMatcher() error = %v", err)
+	}
+	defer matcher.Close()
+
+	registry, err := NewOutboundRegistry(conf)
+	if err != nil {
+		t.Fatalf("NewOutboundRegistry() error = %v", err)
+	}
+	defer registry.Close()
+
+	o := dialOutbound(&state{matcher: matcher, outbounds: registry}, "tcp", "sub.example.com:443")
+	if o == nil {
+		t.Fatal("dialOutbound() = nil, want the legacy PROXY outbound")
+	}
+	if o.Name() != "PROXY" {
+		t.Fatalf("dialOutbound() outbound = %q, want %q", o.Name(), "PROXY")
+	}
+
+	// The SOCKS5 dialer greets the server before this Dial call returns;
+	// its handshake never completes (the fake server only reads), so
+	// ignore the resulting error and only check the bytes it sent.
+	o.Dial(context.Background(), "tcp", "sub.example.com:443")
+
+	select {
+	case got := <-dialed:
+		if len(got) == 0 || got[0] != 0x05 {
+			t.Fatalf("bytes received by fake server = %x, want a SOCKS5 greeting (0x05...)", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a connection through the configured server")
+	}
+}
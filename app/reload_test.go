@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSocks5Server answers the SOCKS5 handshake with a no-auth,
+// connect-succeeded reply so a real socks5Outbound.Dial completes and
+// hands back a usable net.Conn, then hands that conn to the test so it
+// can confirm which configured Server a Dial actually reached.
+type fakeSocks5Server struct {
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func newFakeSocks5Server(t *testing.T) *fakeSocks5Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	s := &fakeSocks5Server{ln: ln, conns: make(chan net.Conn, 8)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handshake(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// handshake completes a minimal no-auth SOCKS5 CONNECT negotiation,
+// then publishes the now-tunneled conn for the test to use.
+func (s *fakeSocks5Server) handshake(conn net.Conn) {
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		conn.Close()
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		conn.Close()
+		return
+	}
+	if _, err := conn.Write([]byte{0x05, 0x00}); err != nil {
+		conn.Close()
+		return
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		conn.Close()
+		return
+	}
+	switch header[3] {
+	case 0x01: // IPv4
+		io.ReadFull(conn, make([]byte, 4+2))
+	case 0x03: // domain name
+		l := make([]byte, 1)
+		io.ReadFull(conn, l)
+		io.ReadFull(conn, make([]byte, int(l[0])+2))
+	case 0x04: // IPv6
+		io.ReadFull(conn, make([]byte, 16+2))
+	}
+	if _, err := conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		conn.Close()
+		return
+	}
+
+	s.conns <- conn
+}
+
+func (s *fakeSocks5Server) addr() string { return s.ln.Addr().String() }
+
+// accept waits for the next fully-negotiated connection, or fails the test.
+func (s *fakeSocks5Server) accept(t *testing.T) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("server never received a connection")
+		return nil
+	}
+}
+
+// writeConfFile writes a minimal legacy-style config naming server as
+// the PROXY outbound for "reload-test.example" and returns its path.
+func writeConfFile(t *testing.T, dir, name, server, gracePeriod string) string {
+	t.Helper()
+	body := fmt.Sprintf(`{
+		"name_server": "udp://127.0.0.1:1",
+		"server": %q,
+		"reload_grace_period": %q,
+		"domain_rules": {"proxy": ["reload-test.example"]}
+	}`, server, gracePeriod)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	return path
+}
+
+// TestReload verifies the guarantees chunk0-5 promised: a successful
+// Reload atomically swaps in the new rules/outbounds for subsequent
+// Dials and refreshes Conf, a connection dialed before Reload keeps
+// working afterward, and a failed Reload leaves the previously active
+// state (including Conf) fully in place.
+func TestReload(t *testing.T) {
+	serverA := newFakeSocks5Server(t)
+	serverB := newFakeSocks5Server(t)
+	dir := t.TempDir()
+
+	pathA := writeConfFile(t, dir, "a.json", serverA.addr(), "1h")
+	app, err := NewApp(pathA, time.Second, os.Stderr)
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	defer app.Close()
+
+	conn1, err := app.Dial(context.Background(), "tcp", "reload-test.example:443")
+	if err != nil {
+		t.Fatalf("Dial() before Reload error = %v", err)
+	}
+	defer conn1.Close()
+	serverA.accept(t)
+	if app.Conf().ReloadGracePeriod != "1h" {
+		t.Fatalf("Conf().ReloadGracePeriod = %q, want %q", app.Conf().ReloadGracePeriod, "1h")
+	}
+
+	pathB := writeConfFile(t, dir, "b.json", serverB.addr(), "2h")
+	if err := app.Reload(pathB); err != nil {
+		t.Fatalf("Reload(b) error = %v", err)
+	}
+	if app.Conf().ReloadGracePeriod != "2h" {
+		t.Fatalf("after Reload(b), Conf().ReloadGracePeriod = %q, want %q (Conf must refresh on Reload)", app.Conf().ReloadGracePeriod, "2h")
+	}
+
+	if _, err := conn1.Write([]byte("still alive")); err != nil {
+		t.Errorf("conn1.Write() after Reload error = %v, want the pre-Reload connection to keep working", err)
+	}
+
+	conn2, err := app.Dial(context.Background(), "tcp", "reload-test.example:443")
+	if err != nil {
+		t.Fatalf("Dial() after Reload error = %v", err)
+	}
+	defer conn2.Close()
+	serverB.accept(t)
+
+	badPath := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badPath, []byte(`{"name_server": "udp://127.0.0.1:1", "reload_grace_period": "not-a-duration"}`), 0o644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	if err := app.Reload(badPath); err == nil {
+		t.Fatal("Reload(bad) error = nil, want a parse error")
+	}
+
+	if app.Conf().ReloadGracePeriod != "2h" {
+		t.Fatalf("after failed Reload, Conf().ReloadGracePeriod = %q, want %q (previous state must stay in place)", app.Conf().ReloadGracePeriod, "2h")
+	}
+
+	conn3, err := app.Dial(context.Background(), "tcp", "reload-test.example:443")
+	if err != nil {
+		t.Fatalf("Dial() after failed Reload error = %v", err)
+	}
+	defer conn3.Close()
+	serverB.accept(t)
+}
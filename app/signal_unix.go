@@ -0,0 +1,33 @@
+// +build !windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSIGHUP spawns a goroutine that calls app.Reload(path) on every
+// SIGHUP, writing any error to the App's output writer rather than
+// returning it. The
+// goroutine exits once app.Close is called.
+func (app *App) HandleSIGHUP(path string) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-app.closed:
+				return
+			case <-sig:
+				if err := app.Reload(path); err != nil {
+					fmt.Fprintf(app.w, "reload %s: %v\n", path, err)
+				}
+			}
+		}
+	}()
+}
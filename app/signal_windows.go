@@ -0,0 +1,8 @@
+// +build windows
+
+package app
+
+// HandleSIGHUP is a no-op on Windows, which has no SIGHUP signal. Use
+// the Clash API's PUT /configs?force=true, or call app.Reload directly,
+// to reload the config on this platform.
+func (app *App) HandleSIGHUP(path string) {}
@@ -0,0 +1,127 @@
+// Package clashapi implements a Clash-compatible RESTful control plane
+// so a running shadow process can be inspected and reconfigured without
+// editing the JSON config and restarting.
+package clashapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imgk/shadow/pkg/outbound"
+	"github.com/imgk/shadow/pkg/rules"
+)
+
+// StateProvider is the subset of App's hot-reloadable state the
+// control API needs: the rule matcher and outbound registry to answer
+// GET /rules and GET /proxies, and Reload to answer PUT /configs. It is
+// satisfied by *github.com/imgk/shadow/app.App.
+type StateProvider interface {
+	Matcher() *rules.Matcher
+	Outbounds() *outbound.Registry
+	Reload(path string) error
+}
+
+// Controller serves the Clash-compatible control API over HTTP. It is
+// attached to an App via App.EnableClashAPI and closed together with it.
+type Controller struct {
+	srv    *http.Server
+	secret string
+
+	state   StateProvider
+	tracker *Tracker
+	logs    *logBroadcaster
+}
+
+// NewController creates a control API bound to addr, guarded by secret.
+// state answers GET /rules, GET /proxies, PUT /proxies/:name and
+// PUT /configs; it is read fresh on every request, so it always
+// reflects the latest App.Reload.
+func NewController(addr, secret string, state StateProvider) *Controller {
+	c := &Controller{
+		secret:  secret,
+		state:   state,
+		tracker: NewTracker(),
+		logs:    newLogBroadcaster(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proxies", c.handleProxies)
+	mux.HandleFunc("/proxies/", c.handleProxy)
+	mux.HandleFunc("/rules", c.handleRules)
+	mux.HandleFunc("/connections", c.handleConnections)
+	mux.HandleFunc("/connections/", c.handleConnection)
+	mux.HandleFunc("/traffic", c.handleTraffic)
+	mux.HandleFunc("/logs", c.handleLogs)
+	mux.HandleFunc("/configs", c.handleConfigs)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	c.srv = &http.Server{
+		Addr:    addr,
+		Handler: c.authenticate(mux),
+	}
+	return c
+}
+
+// Tracker returns the traffic/connection tracker so handlers that dial
+// out on behalf of the client can register every net.Conn they hand
+// back to the caller through Tracker.WrapConn. net.PacketConn values
+// cannot be registered.
+func (c *Controller) Tracker() *Tracker {
+	return c.tracker
+}
+
+// Start begins serving the control API in a background goroutine.
+func (c *Controller) Start() error {
+	ln, err := net.Listen("tcp", c.srv.Addr)
+	if err != nil {
+		return err
+	}
+	go c.srv.Serve(ln)
+	return nil
+}
+
+// Close shuts down the control API. It implements io.Closer so it can be
+// registered with App.attachCloser.
+func (c *Controller) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.srv.Shutdown(ctx)
+}
+
+// Write implements io.Writer so the control API can be spliced into the
+// application logger and fan log lines out to GET /logs subscribers.
+func (c *Controller) Write(b []byte) (int, error) {
+	c.logs.Broadcast(b)
+	return len(b), nil
+}
+
+func (c *Controller) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.secret != "" {
+			auth := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(auth, "Bearer ")
+			// Browser WebSocket clients can't set request headers on the
+			// upgrade request, so /traffic and /logs also accept the
+			// secret as a "token" query parameter, same as upstream Clash.
+			if !secureCompare(token, c.secret) && !(isWebsocketRoute(r.URL.Path) && secureCompare(r.URL.Query().Get("token"), c.secret)) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secureCompare reports whether a and b are equal without leaking how
+// many leading bytes matched through timing, unlike ==.
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func isWebsocketRoute(path string) bool {
+	return path == "/traffic" || path == "/logs"
+}
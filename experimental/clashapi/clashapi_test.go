@@ -0,0 +1,311 @@
+package clashapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imgk/shadow/pkg/outbound"
+	"github.com/imgk/shadow/pkg/rules"
+)
+
+// fakeConn is a no-op net.Conn that reports a fixed amount of data on
+// every Read/Write call, for exercising the Tracker's byte accounting
+// without a real socket.
+type fakeConn struct {
+	readN, writeN int
+}
+
+func (c *fakeConn) Read(b []byte) (int, error)       { return c.readN, nil }
+func (c *fakeConn) Write(b []byte) (int, error)      { return c.writeN, nil }
+func (c *fakeConn) Close() error                     { return nil }
+func (c *fakeConn) LocalAddr() net.Addr              { return nil }
+func (c *fakeConn) RemoteAddr() net.Addr             { return nil }
+func (c *fakeConn) SetDeadline(time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+// fakeState is a minimal StateProvider for exercising the control API
+// without a running App.
+type fakeState struct {
+	matcher    *rules.Matcher
+	outbounds  *outbound.Registry
+	reloadPath string
+	reloadErr  error
+}
+
+func (s *fakeState) Matcher() *rules.Matcher       { return s.matcher }
+func (s *fakeState) Outbounds() *outbound.Registry { return s.outbounds }
+func (s *fakeState) Reload(path string) error {
+	s.reloadPath = path
+	return s.reloadErr
+}
+
+func newTestState(t *testing.T) *fakeState {
+	t.Helper()
+
+	matcher, err := rules.New([]rules.RuleConfig{
+		{Type: "DOMAIN-SUFFIX", Value: "example.com", Outbound: "PROXY"},
+		{Type: "MATCH", Outbound: "DIRECT"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("rules.New() error = %v", err)
+	}
+
+	registry, err := outbound.NewRegistry(
+		[]outbound.OutboundConfig{{Name: "a", Type: "direct"}, {Name: "b", Type: "direct"}},
+		[]outbound.GroupConfig{{Name: "PROXY", Type: "selector", Outbounds: []string{"a", "b"}}},
+	)
+	if err != nil {
+		t.Fatalf("outbound.NewRegistry() error = %v", err)
+	}
+
+	return &fakeState{matcher: matcher, outbounds: registry}
+}
+
+func TestHandleRules(t *testing.T) {
+	c := NewController("127.0.0.1:0", "", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleRules)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/rules")
+	if err != nil {
+		t.Fatalf("GET /rules error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /rules status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var body struct {
+		Rules []ruleInfo `json:"rules"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	if len(body.Rules) != 2 {
+		t.Fatalf("len(Rules) = %d, want 2", len(body.Rules))
+	}
+	if body.Rules[0].Type != "DOMAIN-SUFFIX" || body.Rules[0].Proxy != "PROXY" {
+		t.Errorf("Rules[0] = %+v, want type DOMAIN-SUFFIX outbound PROXY", body.Rules[0])
+	}
+}
+
+func TestHandleProxiesListsOutboundsAndGroups(t *testing.T) {
+	c := NewController("127.0.0.1:0", "", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleProxies)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/proxies")
+	if err != nil {
+		t.Fatalf("GET /proxies error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Proxies map[string]proxyInfo `json:"proxies"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	if _, ok := body.Proxies["a"]; !ok {
+		t.Error(`Proxies["a"] missing, want the "a" outbound listed`)
+	}
+	proxy, ok := body.Proxies["PROXY"]
+	if !ok {
+		t.Fatal(`Proxies["PROXY"] missing, want the selector group listed`)
+	}
+	if proxy.Now != "a" {
+		t.Errorf("Proxies[\"PROXY\"].Now = %q, want %q (selector defaults to first member)", proxy.Now, "a")
+	}
+}
+
+func TestHandleProxySwitchesSelector(t *testing.T) {
+	c := NewController("127.0.0.1:0", "", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleProxy)))
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]string{"name": "b"})
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/proxies/PROXY", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /proxies/PROXY error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT /proxies/PROXY status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	getResp, err := http.Get(srv.URL + "/proxies/PROXY")
+	if err != nil {
+		t.Fatalf("GET /proxies/PROXY error = %v", err)
+	}
+	defer getResp.Body.Close()
+	var info proxyInfo
+	if err := json.NewDecoder(getResp.Body).Decode(&info); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	if info.Now != "b" {
+		t.Errorf("Now = %q after switching, want %q", info.Now, "b")
+	}
+}
+
+func TestHandleProxyRejectsUnknownMember(t *testing.T) {
+	c := NewController("127.0.0.1:0", "", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleProxy)))
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]string{"name": "nope"})
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/proxies/PROXY", bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /proxies/PROXY error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d for an unknown member", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandleConfigsRequiresForce(t *testing.T) {
+	state := newTestState(t)
+	c := NewController("127.0.0.1:0", "", state)
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleConfigs)))
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]string{"path": "/etc/shadow.json"})
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/configs", bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /configs error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status without force=true = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if state.reloadPath != "" {
+		t.Errorf("Reload was called with %q, want not called", state.reloadPath)
+	}
+}
+
+func TestHandleConfigsReloadsOnForce(t *testing.T) {
+	state := newTestState(t)
+	c := NewController("127.0.0.1:0", "", state)
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleConfigs)))
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]string{"path": "/etc/shadow.json"})
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/configs?force=true", bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /configs?force=true error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if state.reloadPath != "/etc/shadow.json" {
+		t.Errorf("Reload path = %q, want %q", state.reloadPath, "/etc/shadow.json")
+	}
+}
+
+func TestAuthenticateRejectsBadSecret(t *testing.T) {
+	c := NewController("127.0.0.1:0", "s3cr3t", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleRules)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/rules")
+	if err != nil {
+		t.Fatalf("GET /rules error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without Authorization header = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/rules", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /rules with Bearer error = %v", err)
+	}
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusOK {
+		t.Errorf("status with correct Bearer secret = %d, want %d", resp2.StatusCode, http.StatusOK)
+	}
+}
+
+func TestAuthenticateAcceptsTokenQueryParamForWebsocketRoutes(t *testing.T) {
+	c := NewController("127.0.0.1:0", "s3cr3t", newTestState(t))
+	srv := httptest.NewServer(c.authenticate(http.HandlerFunc(c.handleRules)))
+	defer srv.Close()
+
+	// /rules is not a websocket route, so the query param fallback must
+	// not apply to it.
+	resp, err := http.Get(srv.URL + "/rules?token=s3cr3t")
+	if err != nil {
+		t.Fatalf("GET /rules?token=... error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status for ?token= on a non-websocket route = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	// /traffic is a websocket route, so the same token passes
+	// authentication there (the wrapped handler itself ignores the path).
+	resp2, err := http.Get(srv.URL + "/traffic?token=s3cr3t")
+	if err != nil {
+		t.Fatalf("GET /traffic?token=... error = %v", err)
+	}
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusOK {
+		t.Errorf("status for ?token= on a websocket route = %d, want %d", resp2.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTrackerAccountsBytesOnFakeConn(t *testing.T) {
+	tracker := NewTracker()
+	conn := tracker.WrapConn(&fakeConn{readN: 10, writeN: 5}, Metadata{Network: "tcp", Rule: "PROXY"})
+
+	buf := make([]byte, 64)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	up, down := tracker.Traffic()
+	if up != 5 || down != 10 {
+		t.Errorf("Traffic() = (up=%d, down=%d), want (up=5, down=10)", up, down)
+	}
+
+	conns := tracker.Conns()
+	if len(conns) != 1 {
+		t.Fatalf("len(Conns()) = %d, want 1", len(conns))
+	}
+	if conns[0].Upload != 5 || conns[0].Download != 10 {
+		t.Errorf("Conns()[0] = %+v, want Upload=5 Download=10", conns[0])
+	}
+
+	if !tracker.Close(conns[0].ID) {
+		t.Fatal("Close() returned false for a tracked connection")
+	}
+	if len(tracker.Conns()) != 0 {
+		t.Error("connection still tracked after Close()")
+	}
+}
+
+func TestTrackerCloseUnknownIDReturnsFalse(t *testing.T) {
+	tracker := NewTracker()
+	if tracker.Close("nonexistent") {
+		t.Error("Close() on an unknown id returned true, want false")
+	}
+}
@@ -0,0 +1,40 @@
+package clashapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleConfigs implements PUT /configs?force=true, re-reading the config
+// file named in the JSON body ({"path": "..."}) and atomically swapping in
+// its rules, outbounds and resolver via StateProvider.Reload. force=true is
+// required: shadow always does a full reload, so there is no partial mode
+// to opt out of.
+func (c *Controller) handleConfigs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("force") != "true" {
+		http.Error(w, "force=true is required", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.state.Reload(body.Path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,40 @@
+package clashapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// handleConnections implements GET /connections: it lists every active
+// proxied connection the Tracker knows about.
+func (c *Controller) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	up, down := c.tracker.Traffic()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"downloadTotal": down,
+		"uploadTotal":   up,
+		"connections":   c.tracker.Conns(),
+	})
+}
+
+// handleConnection implements DELETE /connections/:id, closing one
+// tracked connection.
+func (c *Controller) handleConnection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/connections/")
+	if id == "" || !c.tracker.Close(id) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
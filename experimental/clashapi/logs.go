@@ -0,0 +1,67 @@
+package clashapi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// logBroadcaster fans out raw log lines written through Controller.Write
+// to every GET /logs websocket subscriber.
+type logBroadcaster struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+func newLogBroadcaster() *logBroadcaster {
+	return &logBroadcaster{subs: make(map[chan []byte]struct{})}
+}
+
+// Broadcast delivers p to every current subscriber, dropping it for
+// subscribers that are not keeping up rather than blocking the logger.
+func (b *logBroadcaster) Broadcast(p []byte) {
+	line := append([]byte(nil), p...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+func (b *logBroadcaster) subscribe() chan []byte {
+	ch := make(chan []byte, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *logBroadcaster) unsubscribe(ch chan []byte) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// handleLogs implements GET /logs: it upgrades to a websocket and streams
+// log lines written to the Controller until the client disconnects.
+func (c *Controller) handleLogs(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch := c.logs.subscribe()
+	defer c.logs.unsubscribe(ch)
+
+	for line := range ch {
+		if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+			return
+		}
+	}
+}
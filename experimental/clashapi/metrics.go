@@ -0,0 +1,8 @@
+package clashapi
+
+import "github.com/imgk/shadow/pkg/metrics"
+
+// handleMetrics implements GET /metrics, rendering shadow's runtime
+// counters (connections, traffic, rule matches, DNS queries) in
+// Prometheus text exposition format.
+var handleMetrics = metrics.Handler().ServeHTTP
@@ -0,0 +1,104 @@
+package clashapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// grouper is implemented by every outbound group (selector, urltest,
+// fallback, loadbalance) to report which member is currently in use.
+type grouper interface {
+	Now() string
+	Members() []string
+}
+
+// selectable is implemented by outbound groups whose active member can
+// additionally be switched at runtime, e.g. *outbound.Selector.
+type selectable interface {
+	grouper
+	Set(name string) bool
+}
+
+type delayRecord struct {
+	Time  string `json:"time"`
+	Delay int    `json:"delay"`
+}
+
+type proxyInfo struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Now     string        `json:"now,omitempty"`
+	All     []string      `json:"all,omitempty"`
+	History []delayRecord `json:"history"`
+}
+
+// handleProxies implements GET /proxies: it lists every outbound and
+// group shadow currently knows about, Clash-style.
+func (c *Controller) handleProxies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	proxies := map[string]proxyInfo{}
+	if outbounds := c.state.Outbounds(); outbounds != nil {
+		for name, o := range outbounds.All() {
+			proxies[name] = c.describeProxy(name, o)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"proxies": proxies})
+}
+
+// handleProxy implements GET /proxies/:name and PUT /proxies/:name,
+// switching a selector group to the requested member.
+func (c *Controller) handleProxy(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/proxies/")
+	outbounds := c.state.Outbounds()
+	if name == "" || outbounds == nil {
+		http.NotFound(w, r)
+		return
+	}
+	o, ok := outbounds.Get(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sel, ok := o.(selectable)
+		if !ok {
+			http.Error(w, name+" is not a selector", http.StatusBadRequest)
+			return
+		}
+		if !sel.Set(body.Name) {
+			http.Error(w, "not a member of "+name, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.describeProxy(name, o))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *Controller) describeProxy(name string, o interface{ Type() string }) proxyInfo {
+	info := proxyInfo{Name: name, Type: o.Type(), History: []delayRecord{}}
+	if g, ok := o.(grouper); ok {
+		info.Now = g.Now()
+		info.All = g.Members()
+	}
+	return info
+}
@@ -0,0 +1,31 @@
+package clashapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type ruleInfo struct {
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+	Proxy   string `json:"proxy"`
+}
+
+// handleRules implements GET /rules, enumerating the current rule
+// matcher's ordered rule list.
+func (c *Controller) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info := make([]ruleInfo, 0)
+	if matcher := c.state.Matcher(); matcher != nil {
+		for _, rule := range matcher.Rules() {
+			info = append(info, ruleInfo{Type: rule.Type(), Payload: rule.Payload(), Proxy: rule.Outbound()})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rules": info})
+}
@@ -0,0 +1,142 @@
+package clashapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/imgk/shadow/pkg/metrics"
+)
+
+// Metadata describes one proxied connection for accounting and display
+// purposes.
+type Metadata struct {
+	Network     string `json:"network"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Rule        string `json:"rule"`
+}
+
+// ConnInfo is a point-in-time snapshot of a tracked connection, returned
+// by GET /connections.
+type ConnInfo struct {
+	ID       string    `json:"id"`
+	Metadata Metadata  `json:"metadata"`
+	Upload   uint64    `json:"upload"`
+	Download uint64    `json:"download"`
+	Start    time.Time `json:"start"`
+}
+
+// Tracker is shadow's TrafficController: it records every net.Conn
+// passed to WrapConn in a concurrent map keyed by connection id, so the
+// control API can list and close them and report byte counters. It has
+// no wrapper for net.PacketConn, so packet connections are not tracked.
+type Tracker struct {
+	mu    sync.RWMutex
+	conns map[string]*trackedConn
+
+	up   uint64
+	down uint64
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{conns: make(map[string]*trackedConn)}
+}
+
+// Traffic returns the cumulative upload and download byte counts across
+// every connection the Tracker has ever seen, including closed ones.
+func (t *Tracker) Traffic() (up, down uint64) {
+	return atomic.LoadUint64(&t.up), atomic.LoadUint64(&t.down)
+}
+
+// Conns returns a snapshot of every open connection.
+func (t *Tracker) Conns() []ConnInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]ConnInfo, 0, len(t.conns))
+	for id, tc := range t.conns {
+		out = append(out, ConnInfo{
+			ID:       id,
+			Metadata: tc.Metadata,
+			Upload:   atomic.LoadUint64(&tc.up),
+			Download: atomic.LoadUint64(&tc.down),
+			Start:    tc.start,
+		})
+	}
+	return out
+}
+
+// Close closes and forgets the connection with the given id, returning
+// false if no such connection is tracked.
+func (t *Tracker) Close(id string) bool {
+	t.mu.Lock()
+	tc, ok := t.conns[id]
+	if ok {
+		delete(t.conns, id)
+	}
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	tc.Conn.Close()
+	return true
+}
+
+func (t *Tracker) remove(id string) {
+	t.mu.Lock()
+	delete(t.conns, id)
+	t.mu.Unlock()
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WrapConn registers conn with the Tracker and returns a net.Conn that
+// updates the Tracker's counters on every Read and Write.
+func (t *Tracker) WrapConn(conn net.Conn, meta Metadata) net.Conn {
+	tc := &trackedConn{Conn: conn, Metadata: meta, id: newID(), tracker: t, start: time.Now()}
+	t.mu.Lock()
+	t.conns[tc.id] = tc
+	t.mu.Unlock()
+	metrics.ConnTotal.Inc(meta.Rule, meta.Network)
+	return tc
+}
+
+type trackedConn struct {
+	net.Conn
+	Metadata
+	id      string
+	tracker *Tracker
+	start   time.Time
+
+	up   uint64
+	down uint64
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.down, uint64(n))
+	atomic.AddUint64(&c.tracker.down, uint64(n))
+	metrics.BytesTotal.Add(uint64(n), c.Metadata.Rule, "download")
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.up, uint64(n))
+	atomic.AddUint64(&c.tracker.up, uint64(n))
+	metrics.BytesTotal.Add(uint64(n), c.Metadata.Rule, "upload")
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.remove(c.id)
+	return c.Conn.Close()
+}
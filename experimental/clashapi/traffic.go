@@ -0,0 +1,44 @@
+package clashapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+type trafficSample struct {
+	Up   uint64 `json:"up"`
+	Down uint64 `json:"down"`
+}
+
+// handleTraffic implements GET /traffic: it upgrades to a websocket and
+// streams per-second upload/download byte counts until the client
+// disconnects.
+func (c *Controller) handleTraffic(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastUp, lastDown := c.tracker.Traffic()
+	for range ticker.C {
+		up, down := c.tracker.Traffic()
+		sample := trafficSample{Up: up - lastUp, Down: down - lastDown}
+		lastUp, lastDown = up, down
+
+		if err := conn.WriteJSON(sample); err != nil {
+			return
+		}
+	}
+}
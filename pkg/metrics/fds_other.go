@@ -0,0 +1,8 @@
+// +build !linux
+
+package metrics
+
+// openFDs is not implemented on this platform.
+func openFDs() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,256 @@
+// Package metrics implements a minimal Prometheus text-exposition
+// encoder for shadow's runtime counters, in the spirit of expvar: every
+// CounterVec, HistogramVec and GaugeFunc registers itself into a
+// package-level registry the moment it is created, and WriteText
+// renders all of them on demand. There is no dependency on the
+// prometheus client library; the instruments shadow needs are narrow
+// enough that a ~250-line encoder covers them.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Package-level instruments. Instrumentation sites (the Clash API's
+// connection tracker, pkg/rules and pkg/resolver) record into these directly,
+// expvar-style, rather than threading a Registry through constructors.
+var (
+	ConnTotal      = NewCounterVec("shadow_conn_total", "Total proxied connections dialed.", "outbound", "network")
+	BytesTotal     = NewCounterVec("shadow_bytes_total", "Total bytes transferred.", "outbound", "direction")
+	RuleMatchTotal = NewCounterVec("shadow_rule_match_total", "Total rule list matches.", "type", "value")
+	DNSQueryTotal  = NewCounterVec("shadow_dns_query_total", "Total DNS queries resolved.", "upstream", "rcode")
+	DNSLatency     = NewHistogramVec(
+		"shadow_dns_latency_seconds", "DNS upstream query latency in seconds.",
+		[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		"upstream",
+	)
+)
+
+func init() {
+	NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	NewGaugeFunc("process_open_fds", "Number of open file descriptors.", func() float64 {
+		n, ok := openFDs()
+		if !ok {
+			return 0
+		}
+		return float64(n)
+	})
+}
+
+// collector is anything WriteText can render in Prometheus text
+// exposition format.
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+}
+
+// WriteText renders every registered instrument in Prometheus text
+// exposition format, the format GET /metrics serves.
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, c := range registry {
+		c.writeTo(w)
+	}
+}
+
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// CounterVec is a monotonic counter partitioned by a fixed set of label
+// names, e.g. shadow_conn_total{outbound,network}.
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	n           uint64
+}
+
+// NewCounterVec creates and registers a counter partitioned by
+// labelNames.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by 1. The
+// number and order of labelValues must match the vec's labelNames.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by delta. The
+// number and order of labelValues must match the vec's labelNames.
+func (c *CounterVec) Add(delta uint64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labelValues: labelValues}
+		c.values[key] = v
+	}
+	v.n += delta
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		fmt.Fprintf(w, "%s%s %d\n", c.name, labelSet(c.labelNames, v.labelValues), v.n)
+	}
+}
+
+// HistogramVec is a histogram partitioned by a fixed set of label
+// names, e.g. shadow_dns_latency_seconds{upstream}.
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64 // per-bucket, not yet cumulative
+	sum         float64
+	count       uint64
+}
+
+// NewHistogramVec creates and registers a histogram with the given
+// bucket upper bounds, partitioned by labelNames.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, buckets: buckets, labelNames: labelNames, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+// Observe records v for the given label values. The number and order
+// of labelValues must match the vec's labelNames.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	for i, le := range h.buckets {
+		if v <= le {
+			hv.counts[i]++
+			break
+		}
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		labels := labelSet(h.labelNames, hv.labelValues)
+		cumulative := uint64(0)
+		for i, le := range h.buckets {
+			cumulative += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, leLabelSet(h.labelNames, hv.labelValues, trimFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, leLabelSet(h.labelNames, hv.labelValues, "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, labels, hv.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, hv.count)
+	}
+}
+
+// GaugeFunc is a gauge whose value is computed on demand at scrape
+// time, e.g. the current goroutine count.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc creates and registers a gauge that calls fn each time it
+// is rendered.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.fn())
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch values := m.(type) {
+	case map[string]*counterValue:
+		for k := range values {
+			keys = append(keys, k)
+		}
+	case map[string]*histogramValue:
+		for k := range values {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func labelSet(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func leLabelSet(names, values []string, le string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, values[i]))
+	}
+	pairs = append(pairs, fmt.Sprintf("le=%q", le))
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func trimFloat(f float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%f", f), "0"), ".")
+}
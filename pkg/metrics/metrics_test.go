@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHistogramVecInfBucketIsCumulativeTotal verifies the +Inf bucket
+// chunk0-6 had to fix: it must equal the total observation count, not
+// just the count of samples that missed every finite bucket.
+func TestHistogramVecInfBucketIsCumulativeTotal(t *testing.T) {
+	h := NewHistogramVec("test_histogram_inf_bucket", "test histogram.", []float64{0.1, 1})
+
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var buf strings.Builder
+	h.writeTo(&buf)
+	out := buf.String()
+
+	const want = `test_histogram_inf_bucket_bucket{le="+Inf"} 3`
+	if !strings.Contains(out, want) {
+		t.Fatalf("writeTo() output = %q, want it to contain %q", out, want)
+	}
+
+	const wantCount = "test_histogram_inf_bucket_count 3"
+	if !strings.Contains(out, wantCount) {
+		t.Fatalf("writeTo() output = %q, want it to contain %q", out, wantCount)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Handler returns the GET /metrics handler, rendering every registered
+// instrument in Prometheus text exposition format. Serve uses it for
+// the standalone Admin.Metrics listener; experimental/clashapi uses it
+// to serve /metrics on the same listener as the rest of the control API.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteText(w)
+	})
+}
+
+// Server serves GET /metrics on its own listener, for deployments that
+// want Prometheus scraping without the full Clash API control plane
+// (experimental/clashapi also serves /metrics on its own listener when
+// enabled).
+type Server struct {
+	srv *http.Server
+}
+
+// Serve starts a Server listening on addr.
+func Serve(addr string) (*Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{srv: &http.Server{Addr: addr, Handler: mux}}
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// Close shuts down the server. It implements io.Closer so it can be
+// registered with App.attachCloser.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
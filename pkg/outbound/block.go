@@ -0,0 +1,27 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// errBlocked is returned by blockOutbound.Dial for every destination.
+var errBlocked = errors.New("outbound: connection blocked")
+
+// blockOutbound refuses every dial, for rules that should drop traffic
+// rather than route it anywhere.
+type blockOutbound struct {
+	name string
+}
+
+func newBlockOutbound(name string) *blockOutbound {
+	return &blockOutbound{name: name}
+}
+
+func (o *blockOutbound) Name() string { return o.name }
+func (o *blockOutbound) Type() string { return "Block" }
+
+func (o *blockOutbound) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil, errBlocked
+}
@@ -0,0 +1,23 @@
+package outbound
+
+import (
+	"context"
+	"net"
+)
+
+// directOutbound dials the destination with the host's default route.
+type directOutbound struct {
+	name   string
+	dialer net.Dialer
+}
+
+func newDirectOutbound(name string) *directOutbound {
+	return &directOutbound{name: name}
+}
+
+func (o *directOutbound) Name() string { return o.name }
+func (o *directOutbound) Type() string { return "Direct" }
+
+func (o *directOutbound) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return o.dialer.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,114 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// FallbackGroup health-checks members in order and dials through the
+// first one found healthy.
+type FallbackGroup struct {
+	name     string
+	members  []Outbound
+	url      string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	current string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newFallbackGroup(name string, members []Outbound, url, interval string) (*FallbackGroup, error) {
+	iv, err := parseInterval(interval, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &FallbackGroup{
+		name:     name,
+		members:  members,
+		url:      url,
+		interval: iv,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if len(members) > 0 {
+		g.current = members[0].Name()
+	}
+
+	go g.run()
+	return g, nil
+}
+
+func (g *FallbackGroup) Name() string { return g.name }
+func (g *FallbackGroup) Type() string { return "Fallback" }
+
+// Now returns the name of the currently healthy member in use.
+func (g *FallbackGroup) Now() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.current
+}
+
+// Members returns the names of the group's members, in order.
+func (g *FallbackGroup) Members() []string {
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+func (g *FallbackGroup) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	g.mu.RLock()
+	current := g.current
+	g.mu.RUnlock()
+
+	for _, m := range g.members {
+		if m.Name() == current {
+			return m.Dial(ctx, network, addr)
+		}
+	}
+	return nil, fmt.Errorf("outbound: fallback %s has no members", g.name)
+}
+
+// Close stops the background health-check loop. It implements
+// io.Closer so it can be registered with App.attachCloser.
+func (g *FallbackGroup) Close() error {
+	close(g.stop)
+	<-g.done
+	return nil
+}
+
+func (g *FallbackGroup) run() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	g.check()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			g.check()
+		}
+	}
+}
+
+func (g *FallbackGroup) check() {
+	for _, m := range g.members {
+		if _, err := measureLatency(m, g.url); err == nil {
+			g.mu.Lock()
+			g.current = m.Name()
+			g.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package outbound
+
+import (
+	"time"
+)
+
+// GroupConfig is the JSON representation of one outbound group, e.g.
+// {"name": "auto", "type": "urltest", "outbounds": ["proxy1", "proxy2"], "url": "...", "interval": "5m", "tolerance": 50}.
+type GroupConfig struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Outbounds []string `json:"outbounds"`
+	URL       string   `json:"url,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+	Tolerance int      `json:"tolerance,omitempty"`
+}
+
+// NewGroup builds the group described by cfg from members, which must
+// contain every name in cfg.Outbounds.
+func NewGroup(cfg GroupConfig, members map[string]Outbound) (Outbound, error) {
+	resolved, err := resolveMembers(cfg.Outbounds, members)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "selector":
+		return newSelector(cfg.Name, resolved), nil
+	case "urltest":
+		return newURLTestGroup(cfg.Name, resolved, cfg.URL, cfg.Interval, cfg.Tolerance)
+	case "fallback":
+		return newFallbackGroup(cfg.Name, resolved, cfg.URL, cfg.Interval)
+	case "loadbalance":
+		return newLoadBalanceGroup(cfg.Name, resolved), nil
+	default:
+		return nil, &UnsupportedTypeError{Type: cfg.Type}
+	}
+}
+
+func resolveMembers(names []string, members map[string]Outbound) ([]Outbound, error) {
+	out := make([]Outbound, 0, len(names))
+	for _, name := range names {
+		m, ok := members[name]
+		if !ok {
+			return nil, &UnknownMemberError{Name: name}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// UnknownMemberError is returned by NewGroup when a group's Outbounds
+// list references a name no earlier outbound or group defined.
+type UnknownMemberError struct {
+	Name string
+}
+
+func (e *UnknownMemberError) Error() string {
+	return "outbound: unknown group member " + e.Name
+}
+
+// parseInterval parses s as a Go duration, defaulting to def if s is
+// empty.
+func parseInterval(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,60 @@
+package outbound
+
+import "testing"
+
+func members(names ...string) []Outbound {
+	out := make([]Outbound, len(names))
+	for i, n := range names {
+		out[i] = newDirectOutbound(n)
+	}
+	return out
+}
+
+func TestSelectorDefaultsToFirstMember(t *testing.T) {
+	s := newSelector("auto", members("a", "b", "c"))
+	if got := s.Now(); got != "a" {
+		t.Fatalf("Now() = %q, want %q", got, "a")
+	}
+}
+
+func TestSelectorSet(t *testing.T) {
+	s := newSelector("auto", members("a", "b", "c"))
+
+	if !s.Set("b") {
+		t.Fatal("Set(\"b\") = false, want true")
+	}
+	if got := s.Now(); got != "b" {
+		t.Fatalf("Now() = %q, want %q", got, "b")
+	}
+
+	if s.Set("nope") {
+		t.Fatal("Set(\"nope\") = true, want false")
+	}
+	if got := s.Now(); got != "b" {
+		t.Fatalf("Now() after failed Set = %q, want unchanged %q", got, "b")
+	}
+}
+
+func TestLoadBalanceGroupSticksToSameMember(t *testing.T) {
+	g := newLoadBalanceGroup("lb", members("a", "b", "c"))
+
+	first := g.pick("example.com")
+	for i := 0; i < 10; i++ {
+		if got := g.pick("example.com"); got.Name() != first.Name() {
+			t.Fatalf("pick(%q) = %q on call %d, want stable %q", "example.com", got.Name(), i, first.Name())
+		}
+	}
+}
+
+func TestLoadBalanceGroupSpreadsAcrossMembers(t *testing.T) {
+	g := newLoadBalanceGroup("lb", members("a", "b", "c"))
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		host := string(rune('a' + i%26))
+		seen[g.pick(host).Name()] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("pick only ever returned %v across 100 distinct hosts, want it spread across members", seen)
+	}
+}
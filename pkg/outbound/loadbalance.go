@@ -0,0 +1,77 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+)
+
+// LoadBalanceGroup dials through members chosen by consistent hashing
+// of the destination host, so a given site keeps using the same member
+// across connections.
+type LoadBalanceGroup struct {
+	name    string
+	members []Outbound
+	ring    []ringEntry
+}
+
+type ringEntry struct {
+	hash   uint32
+	member Outbound
+}
+
+const virtualNodesPerMember = 100
+
+func newLoadBalanceGroup(name string, members []Outbound) *LoadBalanceGroup {
+	ring := make([]ringEntry, 0, len(members)*virtualNodesPerMember)
+	for _, m := range members {
+		for i := 0; i < virtualNodesPerMember; i++ {
+			ring = append(ring, ringEntry{hash: hashKey(fmt.Sprintf("%s-%d", m.Name(), i)), member: m})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	return &LoadBalanceGroup{name: name, members: members, ring: ring}
+}
+
+func (g *LoadBalanceGroup) Name() string { return g.name }
+func (g *LoadBalanceGroup) Type() string { return "LoadBalance" }
+
+// Members returns the names of the group's members, in order.
+func (g *LoadBalanceGroup) Members() []string {
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+func (g *LoadBalanceGroup) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(g.ring) == 0 {
+		return nil, fmt.Errorf("outbound: loadbalance %s has no members", g.name)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return g.pick(host).Dial(ctx, network, addr)
+}
+
+// pick returns the member owning key on the hash ring.
+func (g *LoadBalanceGroup) pick(key string) Outbound {
+	h := hashKey(key)
+	i := sort.Search(len(g.ring), func(i int) bool { return g.ring[i].hash >= h })
+	if i == len(g.ring) {
+		i = 0
+	}
+	return g.ring[i].member
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
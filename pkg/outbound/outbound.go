@@ -0,0 +1,56 @@
+// Package outbound implements shadow's concurrent outbound layer: named
+// proxy backends (OutboundConfig) and groups of them (GroupConfig) that
+// pick a member dynamically (selector, urltest, fallback, loadbalance).
+// The rule engine in pkg/rules names an outbound or group as the
+// decision for a connection; a Registry resolves that name to a
+// concrete Outbound to dial.
+package outbound
+
+import (
+	"context"
+	"net"
+)
+
+// Outbound dials a destination through a concrete backend or a group of
+// them.
+type Outbound interface {
+	// Name is the outbound's configured name, as referenced by rules and
+	// by other groups' Outbounds lists.
+	Name() string
+	// Type identifies the kind of outbound, e.g. "Direct", "Socks5",
+	// "Selector", "URLTest", "Fallback", "LoadBalance".
+	Type() string
+	Dial(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// OutboundConfig is the JSON representation of one concrete outbound
+// backend, e.g. {"name": "proxy1", "type": "socks5", "server": "127.0.0.1:1080"}.
+type OutboundConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Server string `json:"server,omitempty"`
+}
+
+// New builds the Outbound described by cfg.
+func New(cfg OutboundConfig) (Outbound, error) {
+	switch cfg.Type {
+	case "direct", "":
+		return newDirectOutbound(cfg.Name), nil
+	case "socks5":
+		return newSocks5Outbound(cfg.Name, cfg.Server)
+	case "block":
+		return newBlockOutbound(cfg.Name), nil
+	default:
+		return nil, &UnsupportedTypeError{Type: cfg.Type}
+	}
+}
+
+// UnsupportedTypeError is returned by New and NewGroup for an
+// unrecognized Type.
+type UnsupportedTypeError struct {
+	Type string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "outbound: unsupported type " + e.Type
+}
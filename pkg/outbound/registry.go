@@ -0,0 +1,59 @@
+package outbound
+
+import "io"
+
+// Registry resolves outbound and group names to the Outbound they were
+// configured as.
+type Registry struct {
+	outbounds map[string]Outbound
+	closers   []io.Closer
+}
+
+// NewRegistry builds every outbound in outbounds, then every group in
+// groups, in order; a group's Outbounds list may reference any
+// outbound or group defined earlier in the combined list.
+func NewRegistry(outbounds []OutboundConfig, groups []GroupConfig) (*Registry, error) {
+	r := &Registry{outbounds: make(map[string]Outbound, len(outbounds)+len(groups))}
+
+	for _, cfg := range outbounds {
+		o, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.outbounds[cfg.Name] = o
+	}
+
+	for _, cfg := range groups {
+		g, err := NewGroup(cfg, r.outbounds)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.outbounds[cfg.Name] = g
+		if c, ok := g.(io.Closer); ok {
+			r.closers = append(r.closers, c)
+		}
+	}
+
+	return r, nil
+}
+
+// Get returns the outbound or group registered under name.
+func (r *Registry) Get(name string) (Outbound, bool) {
+	o, ok := r.outbounds[name]
+	return o, ok
+}
+
+// All returns every registered outbound and group, keyed by name.
+func (r *Registry) All() map[string]Outbound {
+	return r.outbounds
+}
+
+// Close stops every group's background goroutines. It implements
+// io.Closer so it can be registered with App.attachCloser.
+func (r *Registry) Close() error {
+	for _, c := range r.closers {
+		c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Selector is a group whose active member is switched manually, e.g. via
+// the Clash API's PUT /proxies/:name.
+type Selector struct {
+	name    string
+	members []Outbound
+
+	mu      sync.RWMutex
+	current string
+}
+
+func newSelector(name string, members []Outbound) *Selector {
+	s := &Selector{name: name, members: members}
+	if len(members) > 0 {
+		s.current = members[0].Name()
+	}
+	return s
+}
+
+func (s *Selector) Name() string { return s.name }
+func (s *Selector) Type() string { return "Selector" }
+
+// Members returns the names of the selector's members, in order.
+func (s *Selector) Members() []string {
+	names := make([]string, len(s.members))
+	for i, m := range s.members {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+// Now returns the name of the currently selected member.
+func (s *Selector) Now() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Set switches the selector to name, returning false if name is not a
+// member of the group.
+func (s *Selector) Set(name string) bool {
+	for _, m := range s.members {
+		if m.Name() == name {
+			s.mu.Lock()
+			s.current = name
+			s.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Selector) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	for _, m := range s.members {
+		if m.Name() == current {
+			return m.Dial(ctx, network, addr)
+		}
+	}
+	return nil, fmt.Errorf("outbound: selector %s has no members", s.name)
+}
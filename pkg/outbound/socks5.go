@@ -0,0 +1,32 @@
+package outbound
+
+import (
+	"context"
+	"net"
+
+	"golang.org/x/net/proxy"
+)
+
+// socks5Outbound dials the destination through a SOCKS5 proxy.
+type socks5Outbound struct {
+	name   string
+	dialer proxy.Dialer
+}
+
+func newSocks5Outbound(name, server string) (*socks5Outbound, error) {
+	dialer, err := proxy.SOCKS5("tcp", server, nil, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	return &socks5Outbound{name: name, dialer: dialer}, nil
+}
+
+func (o *socks5Outbound) Name() string { return o.name }
+func (o *socks5Outbound) Type() string { return "Socks5" }
+
+func (o *socks5Outbound) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d, ok := o.dialer.(proxy.ContextDialer); ok {
+		return d.DialContext(ctx, network, addr)
+	}
+	return o.dialer.Dial(network, addr)
+}
@@ -0,0 +1,169 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// URLTestGroup periodically measures the round-trip latency of every
+// member through an HTTP GET of url, and dials through whichever member
+// is fastest, among those within tolerance of the best.
+type URLTestGroup struct {
+	name      string
+	members   []Outbound
+	url       string
+	interval  time.Duration
+	tolerance time.Duration
+
+	mu      sync.RWMutex
+	current string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newURLTestGroup(name string, members []Outbound, url, interval string, toleranceMS int) (*URLTestGroup, error) {
+	iv, err := parseInterval(interval, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &URLTestGroup{
+		name:      name,
+		members:   members,
+		url:       url,
+		interval:  iv,
+		tolerance: time.Duration(toleranceMS) * time.Millisecond,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if len(members) > 0 {
+		g.current = members[0].Name()
+	}
+
+	go g.run()
+	return g, nil
+}
+
+func (g *URLTestGroup) Name() string { return g.name }
+func (g *URLTestGroup) Type() string { return "URLTest" }
+
+// Now returns the name of the member currently selected by the latest
+// test round.
+func (g *URLTestGroup) Now() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.current
+}
+
+// Members returns the names of the group's members, in order.
+func (g *URLTestGroup) Members() []string {
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+func (g *URLTestGroup) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	g.mu.RLock()
+	current := g.current
+	g.mu.RUnlock()
+
+	for _, m := range g.members {
+		if m.Name() == current {
+			return m.Dial(ctx, network, addr)
+		}
+	}
+	return nil, fmt.Errorf("outbound: urltest %s has no members", g.name)
+}
+
+// Close stops the background test loop. It implements io.Closer so it
+// can be registered with App.attachCloser.
+func (g *URLTestGroup) Close() error {
+	close(g.stop)
+	<-g.done
+	return nil
+}
+
+func (g *URLTestGroup) run() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	g.test()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			g.test()
+		}
+	}
+}
+
+func (g *URLTestGroup) test() {
+	type result struct {
+		name    string
+		latency time.Duration
+		ok      bool
+	}
+
+	results := make([]result, len(g.members))
+	var wg sync.WaitGroup
+	for i, m := range g.members {
+		wg.Add(1)
+		go func(i int, m Outbound) {
+			defer wg.Done()
+			latency, err := measureLatency(m, g.url)
+			results[i] = result{name: m.Name(), latency: latency, ok: err == nil}
+		}(i, m)
+	}
+	wg.Wait()
+
+	best := ""
+	bestLatency := time.Duration(1<<63 - 1)
+	for _, r := range results {
+		if r.ok && r.latency < bestLatency {
+			best = r.name
+			bestLatency = r.latency
+		}
+	}
+	if best == "" {
+		return
+	}
+
+	// Keep the current member if it's still within tolerance of the
+	// fastest, to avoid flapping between members of similar latency.
+	for _, r := range results {
+		if r.name == g.Now() && r.ok && r.latency-bestLatency <= g.tolerance {
+			return
+		}
+	}
+
+	g.mu.Lock()
+	g.current = best
+	g.mu.Unlock()
+}
+
+// measureLatency dials member and issues an HTTP GET of url through it,
+// returning the round-trip latency.
+func measureLatency(member Outbound, url string) (time.Duration, error) {
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: member.Dial},
+		Timeout:   10 * time.Second,
+	}
+
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// negativeCache remembers NXDOMAIN/NODATA answers for their TTL so
+// repeated lookups for names that don't resolve don't re-query every
+// upstream on every request.
+type negativeCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newNegativeCache() *negativeCache {
+	return &negativeCache{entries: make(map[string]time.Time)}
+}
+
+func negativeKey(m *dns.Msg) string {
+	if len(m.Question) == 0 {
+		return ""
+	}
+	q := m.Question[0]
+	return q.Name + "/" + dns.TypeToString[q.Qtype]
+}
+
+// Negative reports whether m's question is known to negatively resolve.
+func (c *negativeCache) Negative(m *dns.Msg) bool {
+	key := negativeKey(m)
+	if key == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+// Store remembers a negative answer for its SOA-derived TTL, if resp is
+// in fact a negative answer (NXDOMAIN or NOERROR with no answers).
+func (c *negativeCache) Store(m, resp *dns.Msg) {
+	if resp == nil || len(resp.Answer) != 0 {
+		return
+	}
+	if resp.Rcode != dns.RcodeNameError && resp.Rcode != dns.RcodeSuccess {
+		return
+	}
+
+	ttl := uint32(300)
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl = soa.Minttl
+			break
+		}
+	}
+
+	key := negativeKey(m)
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	c.entries[key] = time.Now().Add(time.Duration(ttl) * time.Second)
+	c.mu.Unlock()
+}
@@ -0,0 +1,210 @@
+package resolver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const dnscryptCertMagic = "DNSC"
+const dnscryptServerMagic = "r6fnvWj8"
+
+// dnscryptCert is a resolver's short-term DNSCrypt certificate, fetched
+// as a TXT record at the provider name and signed by the provider's
+// long-term Ed25519 key from the sdns stamp.
+type dnscryptCert struct {
+	resolverPK  [32]byte
+	clientMagic [8]byte
+	serial      uint32
+	tsEnd       uint32
+}
+
+// dnscryptResolver resolves queries over DNSCrypt: an X25519 +
+// XSalsa20-Poly1305 encrypted UDP exchange, per the DNSCrypt protocol
+// specification.
+type dnscryptResolver struct {
+	addr         string
+	providerName string
+	providerPK   ed25519.PublicKey
+
+	udp *dns.Client
+}
+
+func newDNSCryptResolver(addr, providerName string, providerPK []byte) (*dnscryptResolver, error) {
+	if len(providerPK) != ed25519.PublicKeySize {
+		return nil, errors.New("resolver: invalid DNSCrypt provider key")
+	}
+	return &dnscryptResolver{
+		addr:         addr,
+		providerName: providerName,
+		providerPK:   ed25519.PublicKey(providerPK),
+		udp:          &dns.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// fetchCert retrieves and verifies the resolver's current certificate.
+// Resolvers may publish several; the one with the highest serial wins.
+func (r *dnscryptResolver) fetchCert(ctx context.Context) (*dnscryptCert, error) {
+	q := new(dns.Msg)
+	q.SetQuestion(dns.Fqdn(r.providerName), dns.TypeTXT)
+
+	resp, _, err := r.udp.ExchangeContext(ctx, q, r.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *dnscryptCert
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok || len(txt.Txt) == 0 {
+			continue
+		}
+		cert, err := parseDNSCryptCert([]byte(txt.Txt[0]), r.providerPK)
+		if err != nil {
+			continue
+		}
+		if best == nil || cert.serial > best.serial {
+			best = cert
+		}
+	}
+	if best == nil {
+		return nil, errors.New("resolver: no valid DNSCrypt certificate found")
+	}
+	return best, nil
+}
+
+// parseDNSCryptCert decodes and verifies a certificate:
+// magic(4) es-version(2) minor-version(2) signature(64) resolver-pk(32)
+// client-magic(8) serial(4) ts-start(4) ts-end(4).
+func parseDNSCryptCert(raw []byte, providerPK ed25519.PublicKey) (*dnscryptCert, error) {
+	const headerLen = 4 + 2 + 2 + 64 + 32 + 8 + 4 + 4 + 4
+	if len(raw) < headerLen || string(raw[:4]) != dnscryptCertMagic {
+		return nil, errors.New("resolver: malformed DNSCrypt certificate")
+	}
+
+	sig := raw[8 : 8+64]
+	signed := raw[8+64:]
+	if !ed25519.Verify(providerPK, signed, sig) {
+		return nil, errors.New("resolver: DNSCrypt certificate signature invalid")
+	}
+
+	cert := new(dnscryptCert)
+	off := 8 + 64
+	copy(cert.resolverPK[:], raw[off:off+32])
+	off += 32
+	copy(cert.clientMagic[:], raw[off:off+8])
+	off += 8
+	cert.serial = binary.BigEndian.Uint32(raw[off : off+4])
+	cert.tsEnd = binary.BigEndian.Uint32(raw[off+8 : off+12])
+
+	if uint32(time.Now().Unix()) > cert.tsEnd {
+		return nil, errors.New("resolver: DNSCrypt certificate expired")
+	}
+	return cert, nil
+}
+
+func (r *dnscryptResolver) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	cert, err := r.fetchCert(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	padded := padQuery(raw, 64)
+
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:12]); err != nil {
+		return nil, err
+	}
+	sealed := box.Seal(nil, padded, &nonce, &cert.resolverPK, priv)
+
+	packet := make([]byte, 0, len(cert.clientMagic)+len(pub)+12+len(sealed))
+	packet = append(packet, cert.clientMagic[:]...)
+	packet = append(packet, pub[:]...)
+	packet = append(packet, nonce[:12]...)
+	packet = append(packet, sealed...)
+
+	conn, err := net.Dial("udp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	resp := buf[:n]
+
+	if len(resp) < len(dnscryptServerMagic)+24 || string(resp[:len(dnscryptServerMagic)]) != dnscryptServerMagic {
+		return nil, errors.New("resolver: malformed DNSCrypt response")
+	}
+	resp = resp[len(dnscryptServerMagic):]
+
+	var respNonce [24]byte
+	copy(respNonce[:], resp[:24])
+
+	opened, ok := box.Open(nil, resp[24:], &respNonce, &cert.resolverPK, priv)
+	if !ok {
+		return nil, errors.New("resolver: failed to decrypt DNSCrypt response")
+	}
+	unpadded, err := unpadQuery(opened)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(dns.Msg)
+	if err := out.Unpack(unpadded); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *dnscryptResolver) Close() error {
+	return nil
+}
+
+// padQuery applies the DNSCrypt padding scheme: an 0x80 byte followed by
+// zeroes up to the next multiple of blockSize.
+func padQuery(b []byte, blockSize int) []byte {
+	padded := append(append([]byte(nil), b...), 0x80)
+	for len(padded)%blockSize != 0 {
+		padded = append(padded, 0x00)
+	}
+	return padded
+}
+
+func unpadQuery(b []byte) ([]byte, error) {
+	for i := len(b) - 1; i >= 0; i-- {
+		switch b[i] {
+		case 0x80:
+			return b[:i], nil
+		case 0x00:
+			continue
+		default:
+			return nil, errors.New("resolver: invalid DNSCrypt padding")
+		}
+	}
+	return nil, errors.New("resolver: invalid DNSCrypt padding")
+}
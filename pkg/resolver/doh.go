@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/miekg/dns"
+)
+
+// dohResolver resolves queries over DNS-over-HTTPS: an HTTP/2 POST of
+// the wire-format query with Content-Type application/dns-message.
+type dohResolver struct {
+	client *http.Client
+	url    string
+	host   string
+}
+
+func newDoHResolver(addr, host, path string, hashes [][]byte) *dohResolver {
+	tlsConfig := &tls.Config{ServerName: host}
+	if len(hashes) > 0 {
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = pinnedSPKIVerifier(hashes)
+	}
+
+	target := host
+	if addr != "" {
+		target = addr
+	}
+	if path == "" {
+		path = "/dns-query"
+	}
+
+	return &dohResolver{
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true},
+			Timeout:   defaultTimeout,
+		},
+		url:  "https://" + target + path,
+		host: host,
+	}
+}
+
+func (r *dohResolver) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	raw, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	if r.host != "" {
+		req.Host = r.host
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resolver: DoH server returned %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(dns.Msg)
+	if err := out.Unpack(body); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *dohResolver) Close() error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/miekg/dns"
+)
+
+// dotResolver resolves queries over DNS-over-TLS, optionally pinning the
+// upstream certificate's SPKI hash(es) instead of verifying it against
+// the system trust store.
+type dotResolver struct {
+	client *dns.Client
+	addr   string
+}
+
+func newDoTResolver(addr, serverName string, hashes [][]byte) *dotResolver {
+	tlsConfig := &tls.Config{ServerName: serverName}
+	if len(hashes) > 0 {
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = pinnedSPKIVerifier(hashes)
+	}
+	return &dotResolver{
+		client: &dns.Client{Net: "tcp-tls", TLSConfig: tlsConfig, Timeout: defaultTimeout},
+		addr:   addr,
+	}
+}
+
+func (r *dotResolver) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := r.client.ExchangeContext(ctx, m, r.addr)
+	return resp, err
+}
+
+func (r *dotResolver) Close() error {
+	return nil
+}
@@ -0,0 +1,219 @@
+package resolver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// This file implements the one HPKE (RFC 9180) instantiation ODoH needs:
+// base mode, DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM. It is
+// not a general-purpose HPKE implementation: a context only ever seals
+// or opens a single message at sequence number zero, which is all a
+// query/response pair requires.
+const (
+	hpkeKemX25519HKDFSHA256 uint16 = 0x0020
+	hpkeKdfHKDFSHA256       uint16 = 0x0001
+	hpkeAeadAES128GCM       uint16 = 0x0001
+
+	hpkeModeBase byte = 0x00
+
+	hpkeNsecret = 32 // HKDF-SHA256 Nh
+	hpkeNk      = 16 // AES-128-GCM key size
+	hpkeNn      = 12 // AES-128-GCM nonce size
+)
+
+var errUnsupportedSuite = errors.New("resolver: unsupported ODoH HPKE ciphersuite")
+
+// hpkeContext is a one-shot HPKE base-mode encryption context.
+type hpkeContext struct {
+	aead           cipher.AEAD
+	baseNonce      []byte
+	exporterSecret []byte
+	suiteID        []byte
+}
+
+func (c *hpkeContext) seal(aad, pt []byte) []byte {
+	return c.aead.Seal(nil, c.baseNonce, pt, aad)
+}
+
+func (c *hpkeContext) open(aad, ct []byte) ([]byte, error) {
+	return c.aead.Open(nil, c.baseNonce, ct, aad)
+}
+
+// export derives an exported secret of length l, per RFC 9180 5.3.
+func (c *hpkeContext) export(exporterContext []byte, l int) ([]byte, error) {
+	return hpkeLabeledExpand(c.suiteID, c.exporterSecret, []byte("sec"), exporterContext, l)
+}
+
+// i2osp encodes n as a big-endian integer of the given byte length, per
+// RFC 9180's I2OSP.
+func i2osp(n, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+func hpkeSuiteID(kemID, kdfID, aeadID uint16) []byte {
+	id := make([]byte, 0, 10)
+	id = append(id, "HPKE"...)
+	id = append(id, i2osp(int(kemID), 2)...)
+	id = append(id, i2osp(int(kdfID), 2)...)
+	id = append(id, i2osp(int(aeadID), 2)...)
+	return id
+}
+
+func hpkeLabeledExtract(suiteID []byte, salt, label, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, 7+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, "HPKE-v1"...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	return hkdf.Extract(sha256.New, labeledIKM, salt)
+}
+
+func hpkeLabeledExpand(suiteID []byte, prk, label, info []byte, length int) ([]byte, error) {
+	labeledInfo := make([]byte, 0, 2+7+len(suiteID)+len(label)+len(info))
+	labeledInfo = append(labeledInfo, i2osp(length, 2)...)
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, labeledInfo), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// hpkeKeySchedule runs the RFC 9180 base-mode (PSK-less) key schedule
+// over a KEM-derived sharedSecret and returns the resulting one-shot
+// encryption context.
+func hpkeKeySchedule(kemID, kdfID, aeadID uint16, sharedSecret, info []byte) (*hpkeContext, error) {
+	suiteID := hpkeSuiteID(kemID, kdfID, aeadID)
+
+	pskIDHash := hpkeLabeledExtract(suiteID, nil, []byte("psk_id_hash"), nil)
+	infoHash := hpkeLabeledExtract(suiteID, nil, []byte("info_hash"), info)
+
+	ksContext := make([]byte, 0, 1+len(pskIDHash)+len(infoHash))
+	ksContext = append(ksContext, hpkeModeBase)
+	ksContext = append(ksContext, pskIDHash...)
+	ksContext = append(ksContext, infoHash...)
+
+	secret := hpkeLabeledExtract(suiteID, sharedSecret, []byte("secret"), nil)
+
+	key, err := hpkeLabeledExpand(suiteID, secret, []byte("key"), ksContext, hpkeNk)
+	if err != nil {
+		return nil, err
+	}
+	baseNonce, err := hpkeLabeledExpand(suiteID, secret, []byte("base_nonce"), ksContext, hpkeNn)
+	if err != nil {
+		return nil, err
+	}
+	exporterSecret, err := hpkeLabeledExpand(suiteID, secret, []byte("exp"), ksContext, hpkeNsecret)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &hpkeContext{aead: aead, baseNonce: baseNonce, exporterSecret: exporterSecret, suiteID: suiteID}, nil
+}
+
+// hpkeEncap runs DHKEM(X25519, HKDF-SHA256) encapsulation against the
+// recipient's public key pkR, returning the encapsulated key (enc) to
+// send alongside the ciphertext and the shared secret it derives.
+func hpkeEncap(pkR []byte) (enc, sharedSecret []byte, err error) {
+	var skE [32]byte
+	if _, err := rand.Read(skE[:]); err != nil {
+		return nil, nil, err
+	}
+	var pkE [32]byte
+	curve25519.ScalarBaseMult(&pkE, &skE)
+
+	dh, err := curve25519.X25519(skE[:], pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kemSuite := append([]byte("KEM"), i2osp(int(hpkeKemX25519HKDFSHA256), 2)...)
+	kemContext := append(append([]byte{}, pkE[:]...), pkR...)
+
+	eaePRK := hpkeLabeledExtract(kemSuite, nil, []byte("eae_prk"), dh)
+	ss, err := hpkeLabeledExpand(kemSuite, eaePRK, []byte("shared_secret"), kemContext, hpkeNsecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkE[:], ss, nil
+}
+
+// hpkeSealBase runs SetupBaseS followed by a single Seal: it encapsulates
+// a fresh ephemeral key against pkR and seals pt under the resulting
+// context, per RFC 9180 Base mode. The context is also returned so the
+// caller can derive exported secrets from it (ODoH uses this to key the
+// response).
+func hpkeSealBase(kemID, kdfID, aeadID uint16, pkR, info, aad, pt []byte) (enc, ct []byte, ctx *hpkeContext, err error) {
+	if kemID != hpkeKemX25519HKDFSHA256 || kdfID != hpkeKdfHKDFSHA256 || aeadID != hpkeAeadAES128GCM {
+		return nil, nil, nil, errUnsupportedSuite
+	}
+
+	enc, ss, err := hpkeEncap(pkR)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, err = hpkeKeySchedule(kemID, kdfID, aeadID, ss, info)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return enc, ctx.seal(aad, pt), ctx, nil
+}
+
+// hpkeDecap is the recipient side of hpkeEncap: given the sender's
+// encapsulated key enc and the recipient's own key pair (skR, pkR), it
+// derives the same shared secret hpkeEncap produced.
+func hpkeDecap(enc, skR, pkR []byte) (sharedSecret []byte, err error) {
+	dh, err := curve25519.X25519(skR, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	kemSuite := append([]byte("KEM"), i2osp(int(hpkeKemX25519HKDFSHA256), 2)...)
+	kemContext := append(append([]byte{}, enc...), pkR...)
+
+	eaePRK := hpkeLabeledExtract(kemSuite, nil, []byte("eae_prk"), dh)
+	return hpkeLabeledExpand(kemSuite, eaePRK, []byte("shared_secret"), kemContext, hpkeNsecret)
+}
+
+// hpkeOpenBase runs SetupBaseR followed by a single Open: the recipient
+// side of hpkeSealBase.
+func hpkeOpenBase(kemID, kdfID, aeadID uint16, enc, skR, pkR, info, aad, ct []byte) ([]byte, error) {
+	if kemID != hpkeKemX25519HKDFSHA256 || kdfID != hpkeKdfHKDFSHA256 || aeadID != hpkeAeadAES128GCM {
+		return nil, errUnsupportedSuite
+	}
+
+	ss, err := hpkeDecap(enc, skR, pkR)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := hpkeKeySchedule(kemID, kdfID, aeadID, ss, info)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.open(aad, ct)
+}
@@ -0,0 +1,110 @@
+package resolver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func genX25519KeyPair(t *testing.T) (priv, pub []byte) {
+	t.Helper()
+	var sk [32]byte
+	if _, err := rand.Read(sk[:]); err != nil {
+		t.Fatalf("rand.Read() error = %v", err)
+	}
+	var pk [32]byte
+	curve25519.ScalarBaseMult(&pk, &sk)
+	return sk[:], pk[:]
+}
+
+func TestHPKESealOpenRoundTrip(t *testing.T) {
+	skR, pkR := genX25519KeyPair(t)
+
+	info := []byte("odoh query")
+	aad := []byte("associated data")
+	pt := []byte("the quick brown fox")
+
+	enc, ct, _, err := hpkeSealBase(hpkeKemX25519HKDFSHA256, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM, pkR, info, aad, pt)
+	if err != nil {
+		t.Fatalf("hpkeSealBase() error = %v", err)
+	}
+
+	got, err := hpkeOpenBase(hpkeKemX25519HKDFSHA256, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM, enc, skR, pkR, info, aad, ct)
+	if err != nil {
+		t.Fatalf("hpkeOpenBase() error = %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("hpkeOpenBase() = %q, want %q", got, pt)
+	}
+}
+
+func TestHPKEOpenRejectsTamperedCiphertext(t *testing.T) {
+	skR, pkR := genX25519KeyPair(t)
+	info, aad, pt := []byte("odoh query"), []byte("aad"), []byte("message")
+
+	enc, ct, _, err := hpkeSealBase(hpkeKemX25519HKDFSHA256, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM, pkR, info, aad, pt)
+	if err != nil {
+		t.Fatalf("hpkeSealBase() error = %v", err)
+	}
+	ct[0] ^= 0xff
+
+	if _, err := hpkeOpenBase(hpkeKemX25519HKDFSHA256, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM, enc, skR, pkR, info, aad, ct); err == nil {
+		t.Error("hpkeOpenBase() on tampered ciphertext returned nil error, want auth failure")
+	}
+}
+
+func TestHPKESealRejectsUnsupportedSuite(t *testing.T) {
+	_, pkR := genX25519KeyPair(t)
+	if _, _, _, err := hpkeSealBase(0x9999, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM, pkR, nil, nil, []byte("x")); err != errUnsupportedSuite {
+		t.Errorf("hpkeSealBase() with unsupported KEM error = %v, want errUnsupportedSuite", err)
+	}
+}
+
+func TestParseODoHConfigs(t *testing.T) {
+	_, pkR := genX25519KeyPair(t)
+
+	contents := (&odohConfig{kemID: hpkeKemX25519HKDFSHA256, kdfID: hpkeKdfHKDFSHA256, aeadID: hpkeAeadAES128GCM, publicKey: pkR}).encodeContents()
+
+	config := make([]byte, 0)
+	config = append(config, byte(odohConfigVersion>>8), byte(odohConfigVersion))
+	config = append(config, byte(len(contents)>>8), byte(len(contents)))
+	config = append(config, contents...)
+
+	configs := make([]byte, 0)
+	configs = append(configs, byte(len(config)>>8), byte(len(config)))
+	configs = append(configs, config...)
+
+	parsed, err := parseODoHConfigs(configs)
+	if err != nil {
+		t.Fatalf("parseODoHConfigs() error = %v", err)
+	}
+	if len(parsed) != 1 {
+		t.Fatalf("parseODoHConfigs() returned %d configs, want 1", len(parsed))
+	}
+	if parsed[0].kemID != hpkeKemX25519HKDFSHA256 || parsed[0].kdfID != hpkeKdfHKDFSHA256 || parsed[0].aeadID != hpkeAeadAES128GCM {
+		t.Errorf("parseODoHConfigs() ciphersuite = (%x,%x,%x), want (%x,%x,%x)",
+			parsed[0].kemID, parsed[0].kdfID, parsed[0].aeadID,
+			hpkeKemX25519HKDFSHA256, hpkeKdfHKDFSHA256, hpkeAeadAES128GCM)
+	}
+	if !bytes.Equal(parsed[0].publicKey, pkR) {
+		t.Errorf("parseODoHConfigs() publicKey = %x, want %x", parsed[0].publicKey, pkR)
+	}
+}
+
+func TestParseODoHConfigsSkipsUnknownVersion(t *testing.T) {
+	config := []byte{0x00, 0x02, 0x00, 0x00} // version 2, zero-length contents
+	configs := []byte{0x00, byte(len(config))}
+	configs = append(configs, config...)
+
+	if _, err := parseODoHConfigs(configs); err == nil {
+		t.Error("parseODoHConfigs() with only an unsupported version entry returned nil error, want error (no usable configs)")
+	}
+}
+
+func TestParseODoHConfigsRejectsTruncated(t *testing.T) {
+	if _, err := parseODoHConfigs([]byte{0x00, 0x05, 0x00}); err == nil {
+		t.Error("parseODoHConfigs() on truncated input returned nil error, want error")
+	}
+}
@@ -0,0 +1,371 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	odohMessageTypeQuery    byte = 0x01
+	odohMessageTypeResponse byte = 0x02
+
+	odohConfigVersion uint16 = 0x0001
+)
+
+// odohConfig is one parsed ObliviousDoHConfigContents entry from a
+// target's ObliviousDoHConfigs, per RFC 9230 Section 4.1.
+type odohConfig struct {
+	kemID, kdfID, aeadID uint16
+	publicKey            []byte
+}
+
+// keyID identifies this config the way RFC 9230 Section 4.1 does:
+// Expand(Extract("", contents), "odoh key id", Nh).
+func (c *odohConfig) keyID() []byte {
+	contents := c.encodeContents()
+	prk := hkdf.Extract(sha256.New, contents, nil)
+	out := make([]byte, sha256.Size)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh key id")), out)
+	return out
+}
+
+func (c *odohConfig) encodeContents() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, c.kemID)
+	binary.Write(buf, binary.BigEndian, c.kdfID)
+	binary.Write(buf, binary.BigEndian, c.aeadID)
+	binary.Write(buf, binary.BigEndian, uint16(len(c.publicKey)))
+	buf.Write(c.publicKey)
+	return buf.Bytes()
+}
+
+// parseODoHConfigs decodes an ObliviousDoHConfigs TLV structure, per
+// RFC 9230 Section 4.1:
+//
+//	ObliviousDoHConfigs { uint16 Length; ObliviousDoHConfig Configs<...>; }
+//	ObliviousDoHConfig  { uint16 Version; uint16 Length; opaque Contents<...>; }
+//	ObliviousDoHConfigContents { uint16 KemId, KdfId, AeadId; opaque PublicKey<1..>; }
+//
+// Unknown or unsupported config versions/ciphersuites are skipped; the
+// caller picks the first entry it supports.
+func parseODoHConfigs(b []byte) ([]odohConfig, error) {
+	total, b, err := readBE16(b)
+	if err != nil {
+		return nil, err
+	}
+	if int(total) > len(b) {
+		return nil, errors.New("resolver: truncated ObliviousDoHConfigs")
+	}
+	b = b[:total]
+
+	var configs []odohConfig
+	for len(b) > 0 {
+		version, rest, err := readBE16(b)
+		if err != nil {
+			return nil, err
+		}
+		length, rest, err := readBE16(rest)
+		if err != nil {
+			return nil, err
+		}
+		if int(length) > len(rest) {
+			return nil, errors.New("resolver: truncated ObliviousDoHConfig")
+		}
+		contents, rest := rest[:length], rest[length:]
+		b = rest
+
+		if version != odohConfigVersion {
+			continue
+		}
+
+		kemID, contents, err := readBE16(contents)
+		if err != nil {
+			return nil, err
+		}
+		kdfID, contents, err := readBE16(contents)
+		if err != nil {
+			return nil, err
+		}
+		aeadID, contents, err := readBE16(contents)
+		if err != nil {
+			return nil, err
+		}
+		pkLen, contents, err := readBE16(contents)
+		if err != nil {
+			return nil, err
+		}
+		if int(pkLen) > len(contents) {
+			return nil, errors.New("resolver: truncated ObliviousDoHConfigContents")
+		}
+
+		configs = append(configs, odohConfig{
+			kemID:     kemID,
+			kdfID:     kdfID,
+			aeadID:    aeadID,
+			publicKey: append([]byte(nil), contents[:pkLen]...),
+		})
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("resolver: no usable ObliviousDoHConfig entries")
+	}
+	return configs, nil
+}
+
+func readBE16(b []byte) (uint16, []byte, error) {
+	if len(b) < 2 {
+		return 0, nil, errors.New("resolver: truncated ODoH config field")
+	}
+	return binary.BigEndian.Uint16(b), b[2:], nil
+}
+
+// odohResolver implements Oblivious DoH (RFC 9230): the query is sealed
+// under HPKE for the target resolver's public key and POSTed to a relay
+// that cannot see its contents, which forwards it on to the target
+// unmodified.
+//
+// The target's current ObliviousDoHConfig is not carried in its stamp
+// (it is rotated independently of it), so it is fetched and cached from
+// the target's well-known ODoH configuration endpoint on first use.
+type odohResolver struct {
+	client   *http.Client
+	relayURL string
+
+	targetURL string
+	configURL string
+
+	mu     sync.Mutex
+	config *odohConfig
+}
+
+func newODoHResolver(relayURL, targetHost, targetPath string) (*odohResolver, error) {
+	if targetPath == "" {
+		targetPath = "/dns-query"
+	}
+	return &odohResolver{
+		client:    &http.Client{Timeout: defaultTimeout},
+		relayURL:  relayURL,
+		targetURL: "https://" + targetHost + targetPath,
+		configURL: "https://" + targetHost + "/.well-known/odohconfigs",
+	}, nil
+}
+
+// fetchConfig retrieves and caches the target's current ObliviousDoHConfig
+// from its well-known ODoH configuration endpoint.
+func (r *odohResolver) fetchConfig(ctx context.Context) (*odohConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.config != nil {
+		return r.config, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.configURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resolver: fetching ODoH target config returned %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := parseODoHConfigs(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range configs {
+		if configs[i].kemID == hpkeKemX25519HKDFSHA256 && configs[i].kdfID == hpkeKdfHKDFSHA256 && configs[i].aeadID == hpkeAeadAES128GCM {
+			r.config = &configs[i]
+			return r.config, nil
+		}
+	}
+	return nil, errUnsupportedSuite
+}
+
+// encodeMessage serializes an ObliviousDoHMessage:
+//
+//	struct { uint8 MessageType; opaque KeyId<0..>; opaque Message<0..>; }
+func encodeODoHMessage(messageType byte, keyID, message []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(messageType)
+	binary.Write(buf, binary.BigEndian, uint16(len(keyID)))
+	buf.Write(keyID)
+	binary.Write(buf, binary.BigEndian, uint16(len(message)))
+	buf.Write(message)
+	return buf.Bytes()
+}
+
+func decodeODoHMessage(b []byte) (messageType byte, keyID, message []byte, err error) {
+	if len(b) < 1 {
+		return 0, nil, nil, errors.New("resolver: empty ObliviousDoHMessage")
+	}
+	messageType, b = b[0], b[1:]
+
+	n, b, err := readBE16(b)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if int(n) > len(b) {
+		return 0, nil, nil, errors.New("resolver: truncated ObliviousDoHMessage key id")
+	}
+	keyID, b = b[:n], b[n:]
+
+	n, b, err = readBE16(b)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if int(n) > len(b) {
+		return 0, nil, nil, errors.New("resolver: truncated ObliviousDoHMessage message")
+	}
+	message = b[:n]
+	return messageType, keyID, message, nil
+}
+
+func (r *odohResolver) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	config, err := r.fetchConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	// ObliviousDoHQueryBody { opaque DnsMessage<1..>; opaque padding<0..>; };
+	// no padding is added.
+	queryBody := new(bytes.Buffer)
+	binary.Write(queryBody, binary.BigEndian, uint16(len(raw)))
+	queryBody.Write(raw)
+	binary.Write(queryBody, binary.BigEndian, uint16(0))
+
+	keyID := config.keyID()
+	aad := []byte{odohMessageTypeQuery}
+	aad = append(aad, byte(len(keyID)>>8), byte(len(keyID)))
+	aad = append(aad, keyID...)
+
+	enc, ct, hctx, err := hpkeSealBase(config.kemID, config.kdfID, config.aeadID, config.publicKey, []byte("odoh query"), aad, queryBody.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	message := encodeODoHMessage(odohMessageTypeQuery, keyID, append(enc, ct...))
+
+	relay, err := url.Parse(r.relayURL)
+	if err != nil {
+		return nil, err
+	}
+	q := relay.Query()
+	q.Set("targethost", r.targetURL)
+	relay.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, relay.String(), bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/oblivious-dns-message")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resolver: ODoH relay returned %s", resp.Status)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	_, _, respMessage, err := decodeODoHMessage(respBody)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openODoHResponse(hctx, respMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	// ObliviousDoHResponseBody has the same shape as the query body.
+	n, rest, err := readBE16(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if int(n) > len(rest) {
+		return nil, errors.New("resolver: truncated ObliviousDoHResponseBody")
+	}
+
+	out := new(dns.Msg)
+	if err := out.Unpack(rest[:n]); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// openODoHResponse decrypts an ODoH response using the symmetric key the
+// query's HPKE context exports for it (RFC 9230 Section 4.2): the
+// response carries its own AEAD key derived from Context.Export and a
+// fresh nonce, rather than reusing the query's HPKE AEAD key directly.
+func openODoHResponse(hctx *hpkeContext, message []byte) ([]byte, error) {
+	nonceLen := hpkeNn
+	if hpkeNk > nonceLen {
+		nonceLen = hpkeNk
+	}
+	if len(message) < nonceLen {
+		return nil, errors.New("resolver: truncated ODoH response")
+	}
+	responseNonce, ct := message[:nonceLen], message[nonceLen:]
+
+	secret, err := hctx.export([]byte("odoh response"), hpkeNk)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(append([]byte{}, hctx.baseNonce...), responseNonce...)
+	prk := hkdf.Extract(sha256.New, secret, salt)
+	key, err := hpkeLabeledExpand(hctx.suiteID, prk, []byte("odoh response key"), nil, hpkeNk)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hpkeLabeledExpand(hctx.suiteID, prk, []byte("odoh response nonce"), nil, hpkeNn)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, ct, nil)
+}
+
+func (r *odohResolver) Close() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package resolver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+)
+
+// pinnedSPKIVerifier returns a tls.Config.VerifyPeerCertificate callback
+// that accepts the handshake if any certificate presented by the peer
+// has a SHA-256 SubjectPublicKeyInfo hash matching one of pins, per the
+// DNSCrypt stamp specification. It is used in place of normal chain
+// verification when a stamp carries pinned hashes.
+func pinnedSPKIVerifier(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+		}
+		return errors.New("resolver: no certificate matched pinned SPKI hash")
+	}
+}
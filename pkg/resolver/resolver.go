@@ -0,0 +1,207 @@
+// Package resolver dispatches DNS queries to one or more upstream
+// transports: plain UDP/TCP, DNS-over-HTTPS, DNS-over-TLS,
+// DNS-over-QUIC, DNSCrypt and Oblivious DoH. Upstreams are described
+// either as sdns:// stamps or as plain udp://, tls://, https:// and
+// quic:// URIs.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/imgk/shadow/pkg/metrics"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Resolver resolves a single DNS query against one upstream.
+type Resolver interface {
+	Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error)
+	Close() error
+}
+
+// New builds a Resolver for a single upstream URI: an sdns:// stamp, a
+// plain udp://, tls://, https:// or quic:// URI, or an odoh+https://
+// relay URI carrying the target's stamp in a ?target= parameter.
+func New(uri string) (Resolver, error) {
+	if strings.HasPrefix(uri, "sdns://") {
+		st, err := ParseStamp(uri)
+		if err != nil {
+			return nil, err
+		}
+		return newFromStamp(st)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "udp", "":
+		return newUDPResolver(hostPort(u, "53")), nil
+	case "tls":
+		return newDoTResolver(hostPort(u, "853"), u.Hostname(), nil), nil
+	case "https":
+		return newDoHResolver("", u.Hostname(), u.Path, nil), nil
+	case "quic":
+		return newDoQResolver(hostPort(u, "853"), u.Hostname(), nil), nil
+	case "odoh+https":
+		target := u.Query().Get("target")
+		if target == "" {
+			return nil, errors.New("resolver: odoh+https URI missing ?target=<sdns stamp>")
+		}
+		st, err := ParseStamp(target)
+		if err != nil {
+			return nil, err
+		}
+		if st.Proto != ProtoODoHTarget {
+			return nil, errors.New("resolver: ODoH target must be an oblivious DoH target stamp")
+		}
+		relay := *u
+		relay.Scheme = "https"
+		q := relay.Query()
+		q.Del("target")
+		relay.RawQuery = q.Encode()
+		return newODoHResolver(relay.String(), st.ProviderName, st.Path)
+	default:
+		return nil, fmt.Errorf("resolver: unsupported upstream scheme %q", u.Scheme)
+	}
+}
+
+func newFromStamp(st *Stamp) (Resolver, error) {
+	switch st.Proto {
+	case ProtoDNSCrypt:
+		return newDNSCryptResolver(st.Addr, st.ProviderName, st.PublicKey)
+	case ProtoDoH:
+		return newDoHResolver(st.Addr, st.ProviderName, st.Path, st.Hashes), nil
+	case ProtoDoT:
+		return newDoTResolver(st.Addr, st.ProviderName, st.Hashes), nil
+	case ProtoDoQ:
+		return newDoQResolver(st.Addr, st.ProviderName, st.Hashes), nil
+	case ProtoODoHTarget:
+		return nil, errors.New("resolver: an ODoH target stamp must be passed as odoh+https://relay/?target=..., not dialed directly")
+	default:
+		return nil, fmt.Errorf("resolver: unsupported stamp protocol %#x", byte(st.Proto))
+	}
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return u.Hostname() + ":" + defaultPort
+}
+
+// namedUpstream pairs a Resolver with the URI it was built from, so the
+// racer can label shadow_dns_query_total and shadow_dns_latency_seconds
+// by upstream.
+type namedUpstream struct {
+	uri string
+	Resolver
+}
+
+// racer races a query against every upstream in parallel and returns the
+// first successful answer, caching negative answers so repeated lookups
+// for names that don't resolve don't requery every upstream.
+type racer struct {
+	upstreams []namedUpstream
+	negative  *negativeCache
+}
+
+// NewGroup parses every entry in uris (comma-separated, or one per
+// element) and returns a Resolver that races them all for every query.
+func NewGroup(uris []string) (Resolver, error) {
+	r := &racer{negative: newNegativeCache()}
+	for _, uri := range uris {
+		for _, one := range strings.Split(uri, ",") {
+			one = strings.TrimSpace(one)
+			if one == "" {
+				continue
+			}
+			up, err := New(one)
+			if err != nil {
+				return nil, err
+			}
+			r.upstreams = append(r.upstreams, namedUpstream{uri: one, Resolver: up})
+		}
+	}
+	if len(r.upstreams) == 0 {
+		return nil, errors.New("resolver: no upstreams configured")
+	}
+	return r, nil
+}
+
+// observe records a completed upstream query for GET /metrics: a
+// shadow_dns_query_total{upstream,rcode} increment and a
+// shadow_dns_latency_seconds{upstream} observation.
+func observe(upstream string, start time.Time, resp *dns.Msg, err error) {
+	rcode := "error"
+	if err == nil && resp != nil {
+		rcode = dns.RcodeToString[resp.Rcode]
+	}
+	metrics.DNSQueryTotal.Inc(upstream, rcode)
+	metrics.DNSLatency.Observe(time.Since(start).Seconds(), upstream)
+}
+
+func (r *racer) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	if r.negative.Negative(m) {
+		resp := new(dns.Msg)
+		resp.SetRcode(m, dns.RcodeNameError)
+		return resp, nil
+	}
+	if len(r.upstreams) == 1 {
+		start := time.Now()
+		resp, err := r.upstreams[0].Resolve(ctx, m)
+		observe(r.upstreams[0].uri, start, resp, err)
+		if err == nil {
+			r.negative.Store(m, resp)
+		}
+		return resp, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *dns.Msg
+		err  error
+	}
+	results := make(chan result, len(r.upstreams))
+	for _, up := range r.upstreams {
+		up := up
+		go func() {
+			start := time.Now()
+			resp, err := up.Resolve(ctx, m)
+			observe(up.uri, start, resp, err)
+			results <- result{resp, err}
+		}()
+	}
+
+	var lastErr error
+	for range r.upstreams {
+		res := <-results
+		if res.err == nil {
+			r.negative.Store(m, res.resp)
+			return res.resp, nil
+		}
+		lastErr = res.err
+	}
+	return nil, lastErr
+}
+
+func (r *racer) Close() error {
+	var err error
+	for _, up := range r.upstreams {
+		if cerr := up.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
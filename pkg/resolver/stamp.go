@@ -0,0 +1,167 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Protocol identifies the DNS resolution transport encoded in a DNS
+// stamp, per the DNSCrypt stamp specification.
+type Protocol byte
+
+// Stamp protocols supported by ParseStamp.
+const (
+	ProtoDNSCrypt   Protocol = 0x01
+	ProtoDoH        Protocol = 0x02
+	ProtoDoT        Protocol = 0x03
+	ProtoDoQ        Protocol = 0x04
+	ProtoODoHTarget Protocol = 0x05
+)
+
+// Stamp is a parsed sdns:// resolver stamp.
+type Stamp struct {
+	Proto Protocol
+	Props uint64
+
+	Addr         string   // resolver address, host:port
+	Hashes       [][]byte // pinned SPKI hashes (DoH, DoT, DoQ)
+	PublicKey    []byte   // DNSCrypt provider long-term public key
+	ProviderName string   // DNSCrypt provider name, or DoH/DoT/DoQ/ODoH hostname
+	Path         string   // DoH/ODoH path
+}
+
+// ParseStamp decodes an sdns:// resolver stamp: a base64url payload
+// whose first byte is the protocol, followed by protocol-specific
+// length-prefixed fields.
+func ParseStamp(s string) (*Stamp, error) {
+	const prefix = "sdns://"
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errors.New("resolver: not an sdns stamp")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 1 {
+		return nil, errors.New("resolver: empty stamp")
+	}
+
+	st := &Stamp{Proto: Protocol(b[0])}
+	b = b[1:]
+
+	props, rest, err := readUint64(b)
+	if err != nil {
+		return nil, err
+	}
+	st.Props, b = props, rest
+
+	switch st.Proto {
+	case ProtoDNSCrypt:
+		var addr, pk, name []byte
+		if addr, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if pk, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if name, _, err = readLP(b); err != nil {
+			return nil, err
+		}
+		st.Addr, st.PublicKey, st.ProviderName = string(addr), pk, string(name)
+	case ProtoDoH:
+		var addr, host, path []byte
+		if addr, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if st.Hashes, b, err = readLPArray(b); err != nil {
+			return nil, err
+		}
+		if host, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if path, _, err = readLP(b); err != nil {
+			return nil, err
+		}
+		st.Addr, st.ProviderName, st.Path = string(addr), string(host), string(path)
+	case ProtoDoT, ProtoDoQ:
+		var addr, host []byte
+		if addr, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if st.Hashes, b, err = readLPArray(b); err != nil {
+			return nil, err
+		}
+		if host, _, err = readLP(b); err != nil {
+			return nil, err
+		}
+		st.Addr, st.ProviderName = string(addr), string(host)
+	case ProtoODoHTarget:
+		var host, path []byte
+		if host, b, err = readLP(b); err != nil {
+			return nil, err
+		}
+		if path, _, err = readLP(b); err != nil {
+			return nil, err
+		}
+		st.ProviderName, st.Path = string(host), string(path)
+	default:
+		return nil, errors.New("resolver: unsupported stamp protocol")
+	}
+
+	return st, nil
+}
+
+func readUint64(b []byte) (uint64, []byte, error) {
+	if len(b) < 8 {
+		return 0, nil, errors.New("resolver: truncated stamp")
+	}
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v |= uint64(b[i]) << (8 * i)
+	}
+	return v, b[8:], nil
+}
+
+// readLP reads one length-prefixed field: a single length byte followed
+// by that many bytes.
+func readLP(b []byte) ([]byte, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, errors.New("resolver: truncated stamp")
+	}
+	n := int(b[0])
+	b = b[1:]
+	if len(b) < n {
+		return nil, nil, errors.New("resolver: truncated stamp")
+	}
+	return b[:n], b[n:], nil
+}
+
+// readLPArray reads the VLP-encoded set used for the hashes field in
+// DoH/DoT/DoQ stamps: each item is a length byte followed by that many
+// bytes, where the length byte's high bit (0x80) signals that another
+// item follows and the low 7 bits give that item's length. The set
+// ends at the first length byte with the high bit clear; there is no
+// separate terminator.
+func readLPArray(b []byte) ([][]byte, []byte, error) {
+	var out [][]byte
+	for {
+		if len(b) < 1 {
+			return nil, nil, errors.New("resolver: truncated stamp")
+		}
+		n := int(b[0] &^ 0x80)
+		more := b[0]&0x80 != 0
+		b = b[1:]
+		if len(b) < n {
+			return nil, nil, errors.New("resolver: truncated stamp")
+		}
+		if n > 0 {
+			out = append(out, b[:n])
+		}
+		b = b[n:]
+		if !more {
+			return out, b, nil
+		}
+	}
+}
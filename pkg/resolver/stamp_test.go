@@ -0,0 +1,126 @@
+package resolver
+
+import "testing"
+
+func TestParseStampDoH(t *testing.T) {
+	st, err := ParseStamp("sdns://AgAAAAAAAAAAAAAPZG5zLmV4YW1wbGUuY29tCi9kbnMtcXVlcnk")
+	if err != nil {
+		t.Fatalf("ParseStamp() error = %v", err)
+	}
+	if st.Proto != ProtoDoH {
+		t.Errorf("Proto = %v, want ProtoDoH", st.Proto)
+	}
+	if st.ProviderName != "dns.example.com" {
+		t.Errorf("ProviderName = %q, want %q", st.ProviderName, "dns.example.com")
+	}
+	if st.Path != "/dns-query" {
+		t.Errorf("Path = %q, want %q", st.Path, "/dns-query")
+	}
+	if len(st.Hashes) != 0 {
+		t.Errorf("Hashes = %v, want none", st.Hashes)
+	}
+}
+
+// TestParseStampDoHWithPinnedHash decodes a stamp as a spec-compliant
+// generator actually emits it: the hash set's single length byte has
+// its high bit clear (no more items) and there is no trailing
+// terminator byte.
+func TestParseStampDoHWithPinnedHash(t *testing.T) {
+	st, err := ParseStamp("sdns://AgAAAAAAAAAABzEuMS4xLjEgAAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8SY2xvdWRmbGFyZS1kbnMuY29tCi9kbnMtcXVlcnk")
+	if err != nil {
+		t.Fatalf("ParseStamp() error = %v", err)
+	}
+	if st.Addr != "1.1.1.1" {
+		t.Errorf("Addr = %q, want %q", st.Addr, "1.1.1.1")
+	}
+	if st.ProviderName != "cloudflare-dns.com" {
+		t.Errorf("ProviderName = %q, want %q", st.ProviderName, "cloudflare-dns.com")
+	}
+	if st.Path != "/dns-query" {
+		t.Errorf("Path = %q, want %q", st.Path, "/dns-query")
+	}
+	if len(st.Hashes) != 1 || len(st.Hashes[0]) != 32 {
+		t.Fatalf("Hashes = %v, want one 32-byte pin", st.Hashes)
+	}
+	for i, b := range st.Hashes[0] {
+		if b != byte(i) {
+			t.Fatalf("Hashes[0] = %x, want sequential bytes 0x00..0x1f", st.Hashes[0])
+		}
+	}
+}
+
+// TestParseStampDoHWithMultiplePinnedHashes decodes a hash set using
+// the VLP continuation bit (0x80) that chains a second pin, the shape
+// a stamp with both a leaf and an intermediate cert pin would use.
+func TestParseStampDoHWithMultiplePinnedHashes(t *testing.T) {
+	st, err := ParseStamp("sdns://AgAAAAAAAAAABzEuMS4xLjGgAAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8SY2xvdWRmbGFyZS1kbnMuY29tCi9kbnMtcXVlcnk")
+	if err != nil {
+		t.Fatalf("ParseStamp() error = %v", err)
+	}
+	if len(st.Hashes) != 2 || len(st.Hashes[0]) != 32 || len(st.Hashes[1]) != 32 {
+		t.Fatalf("Hashes = %v, want two 32-byte pins", st.Hashes)
+	}
+	for i, b := range st.Hashes[0] {
+		if b != byte(i) {
+			t.Fatalf("Hashes[0] = %x, want sequential bytes 0x00..0x1f", st.Hashes[0])
+		}
+	}
+	for i, b := range st.Hashes[1] {
+		if b != byte(i+32) {
+			t.Fatalf("Hashes[1] = %x, want sequential bytes 0x20..0x3f", st.Hashes[1])
+		}
+	}
+}
+
+func TestParseStampDNSCrypt(t *testing.T) {
+	st, err := ParseStamp("sdns://AQAAAAAAAAAAEjIxMi40Ny4yMjguMTM2OjQ0MyAAAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHxsyLmRuc2NyeXB0LWNlcnQuZXhhbXBsZS5jb20")
+	if err != nil {
+		t.Fatalf("ParseStamp() error = %v", err)
+	}
+	if st.Proto != ProtoDNSCrypt {
+		t.Errorf("Proto = %v, want ProtoDNSCrypt", st.Proto)
+	}
+	if st.Addr != "212.47.228.136:443" {
+		t.Errorf("Addr = %q, want %q", st.Addr, "212.47.228.136:443")
+	}
+	if len(st.PublicKey) != 32 {
+		t.Fatalf("PublicKey len = %d, want 32", len(st.PublicKey))
+	}
+	if st.ProviderName != "2.dnscrypt-cert.example.com" {
+		t.Errorf("ProviderName = %q, want %q", st.ProviderName, "2.dnscrypt-cert.example.com")
+	}
+}
+
+func TestParseStampODoHTarget(t *testing.T) {
+	st, err := ParseStamp("sdns://BQAAAAAAAAAAEG9kb2guZXhhbXBsZS5jb20GL3Byb3h5")
+	if err != nil {
+		t.Fatalf("ParseStamp() error = %v", err)
+	}
+	if st.Proto != ProtoODoHTarget {
+		t.Errorf("Proto = %v, want ProtoODoHTarget", st.Proto)
+	}
+	if st.ProviderName != "odoh.example.com" {
+		t.Errorf("ProviderName = %q, want %q", st.ProviderName, "odoh.example.com")
+	}
+	if st.Path != "/proxy" {
+		t.Errorf("Path = %q, want %q", st.Path, "/proxy")
+	}
+}
+
+func TestParseStampRejectsNonStamp(t *testing.T) {
+	if _, err := ParseStamp("https://example.com/dns-query"); err == nil {
+		t.Error("ParseStamp() on a non-sdns URI returned nil error, want error")
+	}
+}
+
+func TestParseStampRejectsTruncated(t *testing.T) {
+	if _, err := ParseStamp("sdns://Ag"); err == nil {
+		t.Error("ParseStamp() on a truncated payload returned nil error, want error")
+	}
+}
+
+func TestParseStampRejectsUnknownProtocol(t *testing.T) {
+	if _, err := ParseStamp("sdns://_wAAAAAAAAAA"); err == nil {
+		t.Error("ParseStamp() with an unsupported protocol byte returned nil error, want error")
+	}
+}
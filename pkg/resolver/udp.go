@@ -0,0 +1,32 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// udpResolver is the plain DNS backend: the miekg/dns-based resolver
+// that predates DoH/DoT/DoQ/DNSCrypt/ODoH support, kept as the default
+// for udp:// upstreams.
+type udpResolver struct {
+	client *dns.Client
+	addr   string
+}
+
+func newUDPResolver(addr string) *udpResolver {
+	return &udpResolver{client: &dns.Client{Timeout: defaultTimeout}, addr: addr}
+}
+
+func (r *udpResolver) Resolve(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := r.client.ExchangeContext(ctx, m, r.addr)
+	if err == nil && resp != nil && resp.Truncated {
+		tcp := &dns.Client{Net: "tcp", Timeout: defaultTimeout}
+		resp, _, err = tcp.ExchangeContext(ctx, m, r.addr)
+	}
+	return resp, err
+}
+
+func (r *udpResolver) Close() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package rules
+
+import "strings"
+
+type domainMatchFunc func(domain, value string) bool
+
+type domainRule struct {
+	typ      string
+	value    string
+	outbound string
+	match    domainMatchFunc
+}
+
+func newDomainRule(typ, value, outbound string, match domainMatchFunc) *domainRule {
+	return &domainRule{typ: typ, value: strings.ToLower(value), outbound: outbound, match: match}
+}
+
+func (r *domainRule) Match(m *Metadata) bool {
+	if m.Domain == "" {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSuffix(m.Domain, "."))
+	return r.match(domain, r.value)
+}
+
+func (r *domainRule) Outbound() string { return r.outbound }
+func (r *domainRule) Type() string     { return r.typ }
+func (r *domainRule) Payload() string  { return r.value }
+
+func matchDomainExact(domain, value string) bool {
+	return domain == value
+}
+
+func matchDomainSuffix(domain, value string) bool {
+	return domain == value || strings.HasSuffix(domain, "."+value)
+}
+
+func matchDomainKeyword(domain, value string) bool {
+	return strings.Contains(domain, value)
+}
@@ -0,0 +1,39 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+func newRule(cfg RuleConfig, geoDB *maxminddb.Reader) (Rule, error) {
+	switch cfg.Type {
+	case "DOMAIN":
+		return newDomainRule(cfg.Type, cfg.Value, cfg.Outbound, matchDomainExact), nil
+	case "DOMAIN-SUFFIX":
+		return newDomainRule(cfg.Type, cfg.Value, cfg.Outbound, matchDomainSuffix), nil
+	case "DOMAIN-KEYWORD":
+		return newDomainRule(cfg.Type, cfg.Value, cfg.Outbound, matchDomainKeyword), nil
+	case "IP-CIDR", "IP-CIDR6":
+		return newIPCIDRRule(cfg.Type, cfg.Value, cfg.Outbound, false)
+	case "SRC-IP-CIDR":
+		return newIPCIDRRule(cfg.Type, cfg.Value, cfg.Outbound, true)
+	case "GEOIP":
+		if geoDB == nil {
+			return nil, fmt.Errorf("rules: GEOIP rule for %q requires geo_ip_rules.file to be set", cfg.Value)
+		}
+		return newGeoIPRule(cfg.Value, cfg.Outbound, geoDB), nil
+	case "SRC-PORT":
+		return newPortRule(cfg.Type, cfg.Value, cfg.Outbound, true)
+	case "DST-PORT":
+		return newPortRule(cfg.Type, cfg.Value, cfg.Outbound, false)
+	case "NETWORK":
+		return newNetworkRule(cfg.Value, cfg.Outbound), nil
+	case "PROCESS-NAME":
+		return newProcessRule(cfg.Value, cfg.Outbound), nil
+	case "MATCH":
+		return newMatchRule(cfg.Outbound), nil
+	default:
+		return nil, fmt.Errorf("rules: unsupported rule type %q", cfg.Type)
+	}
+}
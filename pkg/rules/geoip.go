@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type geoIPRule struct {
+	country  string
+	outbound string
+	db       *maxminddb.Reader
+}
+
+func newGeoIPRule(country, outbound string, db *maxminddb.Reader) *geoIPRule {
+	return &geoIPRule{country: strings.ToUpper(country), outbound: outbound, db: db}
+}
+
+func (r *geoIPRule) Match(m *Metadata) bool {
+	if m.DstIP == nil {
+		return false
+	}
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := r.db.Lookup(m.DstIP, &record); err != nil {
+		return false
+	}
+	return strings.EqualFold(record.Country.ISOCode, r.country)
+}
+
+func (r *geoIPRule) Outbound() string { return r.outbound }
+func (r *geoIPRule) Type() string     { return "GEOIP" }
+func (r *geoIPRule) Payload() string  { return r.country }
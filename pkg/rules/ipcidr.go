@@ -0,0 +1,30 @@
+package rules
+
+import "net"
+
+type ipCIDRRule struct {
+	typ      string
+	network  *net.IPNet
+	outbound string
+	source   bool
+}
+
+func newIPCIDRRule(typ, value, outbound string, source bool) (*ipCIDRRule, error) {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+	return &ipCIDRRule{typ: typ, network: network, outbound: outbound, source: source}, nil
+}
+
+func (r *ipCIDRRule) Match(m *Metadata) bool {
+	ip := m.DstIP
+	if r.source {
+		ip = m.SrcIP
+	}
+	return ip != nil && r.network.Contains(ip)
+}
+
+func (r *ipCIDRRule) Outbound() string { return r.outbound }
+func (r *ipCIDRRule) Type() string     { return r.typ }
+func (r *ipCIDRRule) Payload() string  { return r.network.String() }
@@ -0,0 +1,14 @@
+package rules
+
+type matchRule struct {
+	outbound string
+}
+
+func newMatchRule(outbound string) *matchRule {
+	return &matchRule{outbound: outbound}
+}
+
+func (r *matchRule) Match(*Metadata) bool { return true }
+func (r *matchRule) Outbound() string     { return r.outbound }
+func (r *matchRule) Type() string         { return "MATCH" }
+func (r *matchRule) Payload() string      { return "" }
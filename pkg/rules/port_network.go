@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type portRule struct {
+	typ      string
+	lo, hi   int
+	outbound string
+	source   bool
+}
+
+func newPortRule(typ, value, outbound string, source bool) (*portRule, error) {
+	lo, hi, err := parsePortRange(value)
+	if err != nil {
+		return nil, err
+	}
+	return &portRule{typ: typ, lo: lo, hi: hi, outbound: outbound, source: source}, nil
+}
+
+func parsePortRange(value string) (int, int, error) {
+	if i := strings.IndexByte(value, '-'); i >= 0 {
+		lo, err := strconv.Atoi(value[:i])
+		if err != nil {
+			return 0, 0, fmt.Errorf("rules: invalid port range %q", value)
+		}
+		hi, err := strconv.Atoi(value[i+1:])
+		if err != nil {
+			return 0, 0, fmt.Errorf("rules: invalid port range %q", value)
+		}
+		return lo, hi, nil
+	}
+
+	p, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("rules: invalid port %q", value)
+	}
+	return p, p, nil
+}
+
+func (r *portRule) Match(m *Metadata) bool {
+	port := m.DstPort
+	if r.source {
+		port = m.SrcPort
+	}
+	return port >= r.lo && port <= r.hi
+}
+
+func (r *portRule) Outbound() string { return r.outbound }
+func (r *portRule) Type() string     { return r.typ }
+func (r *portRule) Payload() string {
+	if r.lo == r.hi {
+		return strconv.Itoa(r.lo)
+	}
+	return fmt.Sprintf("%d-%d", r.lo, r.hi)
+}
+
+type networkRule struct {
+	network  string
+	outbound string
+}
+
+func newNetworkRule(network, outbound string) *networkRule {
+	return &networkRule{network: strings.ToLower(network), outbound: outbound}
+}
+
+func (r *networkRule) Match(m *Metadata) bool {
+	return strings.EqualFold(m.Network, r.network)
+}
+
+func (r *networkRule) Outbound() string { return r.outbound }
+func (r *networkRule) Type() string     { return "NETWORK" }
+func (r *networkRule) Payload() string  { return r.network }
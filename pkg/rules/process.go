@@ -0,0 +1,29 @@
+package rules
+
+import "strings"
+
+// processRule matches the executable name of the local process that
+// owns the connection being dialed, resolved per-OS by lookupProcessName.
+type processRule struct {
+	name     string
+	outbound string
+}
+
+func newProcessRule(name, outbound string) *processRule {
+	return &processRule{name: name, outbound: outbound}
+}
+
+func (r *processRule) Match(m *Metadata) bool {
+	if m.SrcIP == nil {
+		return false
+	}
+	name, err := lookupProcessName(m.Network, m.SrcIP, m.SrcPort)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(name, r.name)
+}
+
+func (r *processRule) Outbound() string { return r.outbound }
+func (r *processRule) Type() string     { return "PROCESS-NAME" }
+func (r *processRule) Payload() string  { return r.name }
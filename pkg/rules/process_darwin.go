@@ -0,0 +1,37 @@
+// +build darwin
+
+package rules
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// lookupProcessName maps a connection's local port to the owning
+// process's executable name by shelling out to lsof, since macOS has no
+// stable public API for this outside of elevated/entitled code.
+func lookupProcessName(network string, srcIP net.IP, srcPort int) (string, error) {
+	proto := "TCP"
+	if strings.HasPrefix(network, "udp") {
+		proto = "UDP"
+	}
+
+	out, err := exec.Command("lsof", "-nP", fmt.Sprintf("-i%s:%d", proto, srcPort)).Output()
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(out), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("rules: no process found for %s:%d", srcIP, srcPort)
+}
@@ -0,0 +1,99 @@
+// +build linux
+
+package rules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lookupProcessName maps a connection's 5-tuple to the executable name
+// of the local process that owns it: it parses /proc/net/{tcp,udp} for
+// the socket's inode, then scans /proc/*/fd for a matching socket link.
+func lookupProcessName(network string, srcIP net.IP, srcPort int) (string, error) {
+	inode, err := findInode(network, srcIP, srcPort)
+	if err != nil {
+		return "", err
+	}
+	return findProcessByInode(inode)
+}
+
+func findInode(network string, srcIP net.IP, srcPort int) (string, error) {
+	table := "tcp"
+	if strings.HasPrefix(network, "udp") {
+		table = "udp"
+	}
+	if srcIP.To4() == nil {
+		table += "6"
+	}
+
+	b, err := ioutil.ReadFile("/proc/net/" + table)
+	if err != nil {
+		return "", err
+	}
+
+	target := fmt.Sprintf("%s:%04X", encodeIP(srcIP), srcPort)
+	for _, line := range strings.Split(string(b), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		if strings.EqualFold(fields[1], target) {
+			return fields[9], nil
+		}
+	}
+	return "", fmt.Errorf("rules: no /proc/net/%s entry for %s:%d", table, srcIP, srcPort)
+}
+
+// encodeIP renders ip in the hex, byte-reversed form /proc/net/{tcp,udp}
+// uses for local/remote addresses.
+func encodeIP(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%02X%02X%02X%02X", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	v6 := ip.To16()
+	var b strings.Builder
+	for i := 0; i < 16; i += 4 {
+		fmt.Fprintf(&b, "%02X%02X%02X%02X", v6[i+3], v6[i+2], v6[i+1], v6[i])
+	}
+	return b.String()
+}
+
+func findProcessByInode(inode string) (string, error) {
+	target := fmt.Sprintf("socket:[%s]", inode)
+
+	procs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return "", err
+	}
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+
+		fds, err := ioutil.ReadDir(filepath.Join("/proc", proc.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", proc.Name(), "fd", fd.Name()))
+			if err != nil || link != target {
+				continue
+			}
+
+			exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+			if err != nil {
+				return "", err
+			}
+			return filepath.Base(exe), nil
+		}
+	}
+	return "", fmt.Errorf("rules: no process found for inode %s", inode)
+}
@@ -0,0 +1,12 @@
+// +build !linux,!windows,!darwin
+
+package rules
+
+import (
+	"errors"
+	"net"
+)
+
+func lookupProcessName(network string, srcIP net.IP, srcPort int) (string, error) {
+	return "", errors.New("rules: PROCESS-NAME matching is not supported on this platform")
+}
@@ -0,0 +1,117 @@
+// +build windows
+
+package rules
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modiphlpapi             = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetExtendedTCPTable = modiphlpapi.NewProc("GetExtendedTcpTable")
+	procGetExtendedUDPTable = modiphlpapi.NewProc("GetExtendedUdpTable")
+)
+
+const (
+	tcpTableOwnerPIDAll = 5
+	udpTableOwnerPID    = 1
+	afInet              = 2
+)
+
+type mibTCPRowOwnerPID struct {
+	State      uint32
+	LocalAddr  uint32
+	LocalPort  uint32
+	RemoteAddr uint32
+	RemotePort uint32
+	OwningPID  uint32
+}
+
+type mibUDPRowOwnerPID struct {
+	LocalAddr uint32
+	LocalPort uint32
+	OwningPID uint32
+}
+
+// lookupProcessName maps a connection's 5-tuple to the executable name
+// of the local process that owns it, via GetExtendedTcpTable /
+// GetExtendedUdpTable followed by QueryFullProcessImageName.
+func lookupProcessName(network string, srcIP net.IP, srcPort int) (string, error) {
+	pid, err := findOwningPID(network, srcIP, srcPort)
+	if err != nil {
+		return "", err
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	buf := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+	return filepath.Base(windows.UTF16ToString(buf[:size])), nil
+}
+
+func findOwningPID(network string, srcIP net.IP, srcPort int) (uint32, error) {
+	v4 := srcIP.To4()
+	if v4 == nil {
+		return 0, fmt.Errorf("rules: process lookup only supports IPv4 on windows")
+	}
+	localAddr := binary.LittleEndian.Uint32(v4)
+	localPort := uint32(srcPort)
+
+	if strings.HasPrefix(network, "udp") {
+		buf, count, err := queryTable(procGetExtendedUDPTable, udpTableOwnerPID)
+		if err != nil {
+			return 0, err
+		}
+		rows := (*[1 << 20]mibUDPRowOwnerPID)(unsafe.Pointer(&buf[4]))[:count:count]
+		for _, row := range rows {
+			if row.LocalAddr == localAddr && swapPort(row.LocalPort) == localPort {
+				return row.OwningPID, nil
+			}
+		}
+		return 0, fmt.Errorf("rules: no UDP socket found for %s:%d", srcIP, srcPort)
+	}
+
+	buf, count, err := queryTable(procGetExtendedTCPTable, tcpTableOwnerPIDAll)
+	if err != nil {
+		return 0, err
+	}
+	rows := (*[1 << 20]mibTCPRowOwnerPID)(unsafe.Pointer(&buf[4]))[:count:count]
+	for _, row := range rows {
+		if row.LocalAddr == localAddr && swapPort(row.LocalPort) == localPort {
+			return row.OwningPID, nil
+		}
+	}
+	return 0, fmt.Errorf("rules: no TCP socket found for %s:%d", srcIP, srcPort)
+}
+
+func queryTable(proc *windows.LazyProc, class uintptr) ([]byte, uint32, error) {
+	var size uint32
+	proc.Call(0, uintptr(unsafe.Pointer(&size)), 0, afInet, class, 0)
+
+	buf := make([]byte, size)
+	ret, _, _ := proc.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0, afInet, class, 0)
+	if ret != 0 {
+		return nil, 0, fmt.Errorf("rules: extended owner-PID table query failed: %d", ret)
+	}
+	return buf, *(*uint32)(unsafe.Pointer(&buf[0])), nil
+}
+
+// swapPort converts the big-endian port encoding the MIB_*ROW_OWNER_PID
+// structures use into host byte order.
+func swapPort(p uint32) uint32 {
+	return uint32(byte(p))<<8 | uint32(byte(p>>8))
+}
@@ -0,0 +1,93 @@
+// Package rules implements shadow's rule engine: an ordered list of
+// matchers, each binding a DOMAIN/IP/port/process condition to an
+// outbound, evaluated in order with the first match winning.
+package rules
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/imgk/shadow/pkg/metrics"
+)
+
+// Metadata describes a connection being matched against the rule list.
+type Metadata struct {
+	Network string // "tcp" or "udp"
+	SrcIP   net.IP
+	SrcPort int
+	DstIP   net.IP
+	DstPort int
+	Domain  string // domain name originally dialed, if any
+}
+
+// Rule is one entry in an ordered rule list.
+type Rule interface {
+	Match(m *Metadata) bool
+	Outbound() string
+	Type() string
+	// Payload is the rule's matched value, for introspection such as the
+	// control API's GET /rules. It is empty for rules with no payload
+	// (e.g. MATCH).
+	Payload() string
+}
+
+// RuleConfig is the JSON representation of one rule list entry, e.g.
+// {"type": "DOMAIN-SUFFIX", "value": "google.com", "outbound": "PROXY"}.
+type RuleConfig struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Outbound string `json:"outbound"`
+}
+
+// Matcher evaluates an ordered list of rules, returning the outbound of
+// the first one that matches.
+type Matcher struct {
+	rules []Rule
+	geoDB *maxminddb.Reader
+}
+
+// New builds a Matcher from cfgs, in order. geoDB may be nil as long as
+// cfgs contains no GEOIP rule; if non-nil, the Matcher takes ownership
+// of it and closes it in Close.
+func New(cfgs []RuleConfig, geoDB *maxminddb.Reader) (*Matcher, error) {
+	m := &Matcher{rules: make([]Rule, 0, len(cfgs)), geoDB: geoDB}
+	for _, cfg := range cfgs {
+		r, err := newRule(cfg, geoDB)
+		if err != nil {
+			if geoDB != nil {
+				geoDB.Close()
+			}
+			return nil, err
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Close closes the GeoIP database the Matcher was built with, if any.
+// It implements io.Closer so it can be registered with App.attachCloser.
+func (m *Matcher) Close() error {
+	if m.geoDB != nil {
+		return m.geoDB.Close()
+	}
+	return nil
+}
+
+// Match returns the outbound of the first rule matching meta, and false
+// if none did (including no final MATCH rule).
+func (m *Matcher) Match(meta *Metadata) (string, bool) {
+	for _, r := range m.rules {
+		if r.Match(meta) {
+			metrics.RuleMatchTotal.Inc(r.Type(), r.Payload())
+			return r.Outbound(), true
+		}
+	}
+	return "", false
+}
+
+// Rules returns the ordered rule list, for introspection such as the
+// control API's GET /rules.
+func (m *Matcher) Rules() []Rule {
+	return m.rules
+}
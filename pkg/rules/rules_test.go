@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatcherFirstMatchWins(t *testing.T) {
+	m, err := New([]RuleConfig{
+		{Type: "DOMAIN-SUFFIX", Value: "google.com", Outbound: "PROXY"},
+		{Type: "DOMAIN", Value: "example.com", Outbound: "DIRECT"},
+		{Type: "MATCH", Outbound: "BLOCKED"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"mail.google.com", "PROXY"},
+		{"google.com", "PROXY"},
+		{"example.com", "DIRECT"},
+		{"sub.example.com", "BLOCKED"}, // DOMAIN is exact, not a suffix match
+		{"anything-else.net", "BLOCKED"},
+	}
+	for _, tt := range tests {
+		got, ok := m.Match(&Metadata{Domain: tt.domain})
+		if !ok || got != tt.want {
+			t.Errorf("Match(%q) = (%q, %v), want (%q, true)", tt.domain, got, ok, tt.want)
+		}
+	}
+}
+
+func TestMatcherNoMatchWithoutFinalRule(t *testing.T) {
+	m, err := New([]RuleConfig{
+		{Type: "DOMAIN", Value: "example.com", Outbound: "DIRECT"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, ok := m.Match(&Metadata{Domain: "other.com"}); ok {
+		t.Error("Match() matched with no applicable rule and no MATCH fallback, want false")
+	}
+}
+
+func TestMatcherIPCIDR(t *testing.T) {
+	m, err := New([]RuleConfig{
+		{Type: "IP-CIDR", Value: "10.0.0.0/8", Outbound: "DIRECT"},
+		{Type: "SRC-IP-CIDR", Value: "192.168.0.0/16", Outbound: "BLOCKED"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if got, ok := m.Match(&Metadata{DstIP: net.ParseIP("10.1.2.3")}); !ok || got != "DIRECT" {
+		t.Errorf("Match(DstIP in 10.0.0.0/8) = (%q, %v), want (DIRECT, true)", got, ok)
+	}
+	if got, ok := m.Match(&Metadata{SrcIP: net.ParseIP("192.168.1.1")}); !ok || got != "BLOCKED" {
+		t.Errorf("Match(SrcIP in 192.168.0.0/16) = (%q, %v), want (BLOCKED, true)", got, ok)
+	}
+	if _, ok := m.Match(&Metadata{DstIP: net.ParseIP("8.8.8.8")}); ok {
+		t.Error("Match(DstIP outside any CIDR) matched, want false")
+	}
+}
+
+func TestMatcherPortAndNetwork(t *testing.T) {
+	m, err := New([]RuleConfig{
+		{Type: "DST-PORT", Value: "80", Outbound: "DIRECT"},
+		{Type: "SRC-PORT", Value: "1024-2048", Outbound: "BLOCKED"},
+		{Type: "NETWORK", Value: "udp", Outbound: "PROXY"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if got, ok := m.Match(&Metadata{DstPort: 80}); !ok || got != "DIRECT" {
+		t.Errorf("Match(DstPort=80) = (%q, %v), want (DIRECT, true)", got, ok)
+	}
+	if got, ok := m.Match(&Metadata{SrcPort: 1500}); !ok || got != "BLOCKED" {
+		t.Errorf("Match(SrcPort=1500) = (%q, %v), want (BLOCKED, true)", got, ok)
+	}
+	if got, ok := m.Match(&Metadata{Network: "UDP"}); !ok || got != "PROXY" {
+		t.Errorf("Match(Network=UDP) = (%q, %v), want (PROXY, true) (case-insensitive)", got, ok)
+	}
+}
+
+func TestNewRejectsUnsupportedRuleType(t *testing.T) {
+	if _, err := New([]RuleConfig{{Type: "NOT-A-TYPE"}}, nil); err == nil {
+		t.Error("New() with unsupported rule type returned nil error, want error")
+	}
+}
+
+func TestNewRejectsGeoIPWithoutDatabase(t *testing.T) {
+	if _, err := New([]RuleConfig{{Type: "GEOIP", Value: "US", Outbound: "DIRECT"}}, nil); err == nil {
+		t.Error("New() with GEOIP rule and nil geoDB returned nil error, want error")
+	}
+}